@@ -0,0 +1,2233 @@
+package main
+
+import (
+	"database/sql"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// ddl token kinds
+const (
+	tokIdent = iota
+	tokQuotedIdent
+	tokString
+	tokNumber
+	tokPunct
+)
+
+type ddlToken struct {
+	kind int
+	text string
+	pos  int
+	end  int
+}
+
+// unescapeDDL decode the backslash escape at the start of s, the text after the
+// backslash of an E'...' string, returning the text and the number of bytes used
+func unescapeDDL(s string) (string, int) {
+	switch s[0] {
+	case 'b':
+		return "\b", 1
+	case 'f':
+		return "\f", 1
+	case 'n':
+		return "\n", 1
+	case 'r':
+		return "\r", 1
+	case 't':
+		return "\t", 1
+	case '0', '1', '2', '3', '4', '5', '6', '7':
+		n := 1
+		for n < 3 && n < len(s) && s[n] >= '0' && s[n] <= '7' {
+			n++
+		}
+		v, _ := strconv.ParseUint(s[:n], 8, 8)
+		return string([]byte{byte(v)}), n
+	case 'x', 'u', 'U':
+		digits := 2
+		if s[0] == 'u' {
+			digits = 4
+		} else if s[0] == 'U' {
+			digits = 8
+		}
+		n := 1
+		for n <= digits && n < len(s) && strings.IndexByte("0123456789abcdefABCDEF", s[n]) >= 0 {
+			n++
+		}
+		if n == 1 {
+			break
+		}
+		v, _ := strconv.ParseUint(s[1:n], 16, 32)
+		if s[0] == 'x' {
+			return string([]byte{byte(v)}), n
+		}
+		return string(rune(v)), n
+	}
+	return s[:1], 1
+}
+
+// tokenizeDDL split src into tokens, dropping whitespace and comments
+func tokenizeDDL(src string) ([]ddlToken, error) {
+	var toks []ddlToken
+	i := 0
+	for i < len(src) {
+		c := src[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f':
+			i++
+		case strings.HasPrefix(src[i:], "--"):
+			for i < len(src) && src[i] != '\n' {
+				i++
+			}
+		case strings.HasPrefix(src[i:], "/*"):
+			depth := 0
+			for i < len(src) {
+				if strings.HasPrefix(src[i:], "/*") {
+					depth++
+					i += 2
+				} else if strings.HasPrefix(src[i:], "*/") {
+					depth--
+					i += 2
+					if depth == 0 {
+						break
+					}
+				} else {
+					i++
+				}
+			}
+			if depth != 0 {
+				return nil, errors.New("unterminated comment")
+			}
+		case c == '\'' || ((c == 'E' || c == 'e') && i+1 < len(src) && src[i+1] == '\''):
+			start := i
+			escape := c != '\''
+			if escape {
+				i++
+			}
+			var b strings.Builder
+			i++
+			closed := false
+			for i < len(src) {
+				if escape && src[i] == '\\' && i+1 < len(src) {
+					s, n := unescapeDDL(src[i+1:])
+					b.WriteString(s)
+					i += 1 + n
+					continue
+				}
+				if src[i] == '\'' {
+					if i+1 < len(src) && src[i+1] == '\'' {
+						b.WriteByte('\'')
+						i += 2
+						continue
+					}
+					i++
+					closed = true
+					break
+				}
+				b.WriteByte(src[i])
+				i++
+			}
+			if !closed {
+				return nil, errors.New("unterminated string literal")
+			}
+			toks = append(toks, ddlToken{kind: tokString, text: b.String(), pos: start, end: i})
+		case c == '"':
+			start := i
+			var b strings.Builder
+			i++
+			closed := false
+			for i < len(src) {
+				if src[i] == '"' {
+					if i+1 < len(src) && src[i+1] == '"' {
+						b.WriteByte('"')
+						i += 2
+						continue
+					}
+					i++
+					closed = true
+					break
+				}
+				b.WriteByte(src[i])
+				i++
+			}
+			if !closed {
+				return nil, errors.New("unterminated quoted identifier")
+			}
+			toks = append(toks, ddlToken{kind: tokQuotedIdent, text: b.String(), pos: start, end: i})
+		case c == '$' && dollarTag(src[i:]) != "":
+			start := i
+			tag := dollarTag(src[i:])
+			j := strings.Index(src[i+len(tag):], tag)
+			if j < 0 {
+				return nil, errors.Errorf("unterminated dollar quoted string %s", tag)
+			}
+			body := src[i+len(tag) : i+len(tag)+j]
+			i += len(tag) + j + len(tag)
+			toks = append(toks, ddlToken{kind: tokString, text: body, pos: start, end: i})
+		case isIdentStart(c):
+			start := i
+			for i < len(src) && isIdentChar(src[i]) {
+				i++
+			}
+			toks = append(toks, ddlToken{kind: tokIdent, text: strings.ToLower(src[start:i]), pos: start, end: i})
+		case c >= '0' && c <= '9':
+			start := i
+			for i < len(src) && (src[i] >= '0' && src[i] <= '9' || src[i] == '.') {
+				i++
+			}
+			toks = append(toks, ddlToken{kind: tokNumber, text: src[start:i], pos: start, end: i})
+		case strings.HasPrefix(src[i:], "::"):
+			toks = append(toks, ddlToken{kind: tokPunct, text: "::", pos: i, end: i + 2})
+			i += 2
+		default:
+			toks = append(toks, ddlToken{kind: tokPunct, text: string(c), pos: i, end: i + 1})
+			i++
+		}
+	}
+	return toks, nil
+}
+
+func isIdentStart(c byte) bool {
+	return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= 0x80
+}
+
+func isIdentChar(c byte) bool {
+	return isIdentStart(c) || c >= '0' && c <= '9' || c == '$'
+}
+
+// dollarTag return $tag$ at the start of s, if any
+func dollarTag(s string) string {
+	for i := 1; i < len(s); i++ {
+		if s[i] == '$' {
+			return s[:i+1]
+		}
+		if !isIdentChar(s[i]) || s[i] == '$' || (i == 1 && s[i] >= '0' && s[i] <= '9') {
+			return ""
+		}
+	}
+	return ""
+}
+
+// splitStatements split tokens at top level semicolons
+func splitStatements(toks []ddlToken) [][]ddlToken {
+	var stmts [][]ddlToken
+	depth, start := 0, 0
+	for i, t := range toks {
+		if t.kind != tokPunct {
+			continue
+		}
+		switch t.text {
+		case "(":
+			depth++
+		case ")":
+			depth--
+		case ";":
+			if depth == 0 {
+				if i > start {
+					stmts = append(stmts, toks[start:i])
+				}
+				start = i + 1
+			}
+		}
+	}
+	if start < len(toks) {
+		stmts = append(stmts, toks[start:])
+	}
+	return stmts
+}
+
+// ddlParser cursor over the tokens of one statement
+type ddlParser struct {
+	src  string
+	toks []ddlToken
+	i    int
+}
+
+func (p *ddlParser) done() bool {
+	return p.i >= len(p.toks)
+}
+
+func (p *ddlParser) peek() ddlToken {
+	if p.done() {
+		return ddlToken{kind: tokPunct, pos: len(p.src), end: len(p.src)}
+	}
+	return p.toks[p.i]
+}
+
+func (p *ddlParser) next() ddlToken {
+	t := p.peek()
+	if !p.done() {
+		p.i++
+	}
+	return t
+}
+
+// isWord check if t is the given unquoted keyword or punctuation
+func isWord(t ddlToken, w string) bool {
+	return (t.kind == tokIdent || t.kind == tokPunct) && t.text == w
+}
+
+// peekWords check if the next tokens are words, without consuming them
+func (p *ddlParser) peekWords(words ...string) bool {
+	for k, w := range words {
+		if p.i+k >= len(p.toks) || !isWord(p.toks[p.i+k], w) {
+			return false
+		}
+	}
+	return true
+}
+
+// accept consume words if the next tokens match all of them
+func (p *ddlParser) accept(words ...string) bool {
+	if !p.peekWords(words...) {
+		return false
+	}
+	p.i += len(words)
+	return true
+}
+
+func (p *ddlParser) expect(words ...string) error {
+	if !p.accept(words...) {
+		return errors.Errorf("expected %s near %q", strings.Join(words, " "), p.context())
+	}
+	return nil
+}
+
+// context source text of the statement from the next token on, at most 40 bytes
+func (p *ddlParser) context() string {
+	t := p.peek()
+	end := t.pos + 40
+	if len(p.toks) != 0 && end > p.toks[len(p.toks)-1].end {
+		end = p.toks[len(p.toks)-1].end
+	}
+	if end < t.pos {
+		end = t.pos
+	}
+	return p.src[t.pos:end]
+}
+
+func (p *ddlParser) ident() (string, error) {
+	t := p.peek()
+	if p.done() || (t.kind != tokIdent && t.kind != tokQuotedIdent) {
+		return "", errors.Errorf("expected identifier near %q", p.context())
+	}
+	p.next()
+	return t.text, nil
+}
+
+// qualifiedName parse [schema.]name, defaulting to schema
+func (p *ddlParser) qualifiedName(schema string) (string, string, error) {
+	name, err := p.ident()
+	if err != nil {
+		return "", "", err
+	}
+	if p.accept(".") {
+		s := name
+		if name, err = p.ident(); err != nil {
+			return "", "", err
+		}
+		return s, name, nil
+	}
+	return schema, name, nil
+}
+
+// identList parse ( a, b, c )
+func (p *ddlParser) identList() ([]string, error) {
+	if err := p.expect("("); err != nil {
+		return nil, err
+	}
+	var names []string
+	for {
+		name, err := p.ident()
+		if err != nil {
+			return nil, err
+		}
+		names = append(names, name)
+		if p.accept(")") {
+			return names, nil
+		}
+		if err := p.expect(","); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// group consume a balanced parenthesized group and return its inner tokens
+func (p *ddlParser) group() ([]ddlToken, error) {
+	if err := p.expect("("); err != nil {
+		return nil, err
+	}
+	start, depth := p.i, 1
+	for !p.done() {
+		t := p.next()
+		if isWord(t, "(") {
+			depth++
+		} else if isWord(t, ")") {
+			depth--
+			if depth == 0 {
+				return p.toks[start : p.i-1], nil
+			}
+		}
+	}
+	return nil, errors.New("unbalanced parentheses")
+}
+
+// until consume tokens up to, not including, a top level token matching stop
+func (p *ddlParser) until(stop func(t ddlToken) bool) []ddlToken {
+	start, depth := p.i, 0
+	for !p.done() {
+		t := p.peek()
+		if depth == 0 && (stop(t) || isWord(t, ")")) {
+			break
+		}
+		if isWord(t, "(") {
+			depth++
+		} else if isWord(t, ")") {
+			depth--
+		}
+		p.i++
+	}
+	return p.toks[start:p.i]
+}
+
+// text source text spanned by toks with whitespace collapsed
+func (p *ddlParser) text(toks []ddlToken) string {
+	if len(toks) == 0 {
+		return ""
+	}
+	return strings.Join(strings.Fields(p.src[toks[0].pos:toks[len(toks)-1].end]), " ")
+}
+
+// bound partition bound spec with keywords upper cased, as pg_get_expr prints it
+func (p *ddlParser) bound(toks []ddlToken) string {
+	var b strings.Builder
+	last := 0
+	if len(toks) > 0 {
+		last = toks[0].pos
+	}
+	for _, t := range toks {
+		b.WriteString(p.src[last:t.pos])
+		word := p.src[t.pos:t.end]
+		if t.kind == tokIdent {
+			switch t.text {
+			case "for", "values", "from", "to", "in", "with", "modulus", "remainder", "default", "minvalue", "maxvalue":
+				word = strings.ToUpper(word)
+			}
+		}
+		b.WriteString(word)
+		last = t.end
+	}
+	return strings.Join(strings.Fields(b.String()), " ")
+}
+
+// splitTop split toks at top level commas
+func splitTop(toks []ddlToken) [][]ddlToken {
+	var parts [][]ddlToken
+	depth, start := 0, 0
+	for i, t := range toks {
+		switch {
+		case isWord(t, "("):
+			depth++
+		case isWord(t, ")"):
+			depth--
+		case isWord(t, ",") && depth == 0:
+			parts = append(parts, toks[start:i])
+			start = i + 1
+		}
+	}
+	if start < len(toks) {
+		parts = append(parts, toks[start:])
+	}
+	return parts
+}
+
+func (p *ddlParser) sub(toks []ddlToken) *ddlParser {
+	return &ddlParser{src: p.src, toks: toks}
+}
+
+// canonical names of builtin types as printed by format_type, and their pg_type names
+var ddlTypeAliases = map[string]string{
+	"int":         "integer",
+	"int4":        "integer",
+	"serial":      "integer",
+	"serial4":     "integer",
+	"int8":        "bigint",
+	"bigserial":   "bigint",
+	"serial8":     "bigint",
+	"int2":        "smallint",
+	"smallserial": "smallint",
+	"serial2":     "smallint",
+	"bool":        "boolean",
+	"varchar":     "character varying",
+	"char":        "character",
+	"float":       "double precision",
+	"float8":      "double precision",
+	"float4":      "real",
+	"decimal":     "numeric",
+	"timestamp":   "timestamp without time zone",
+	"timestamptz": "timestamp with time zone",
+	"time":        "time without time zone",
+	"timetz":      "time with time zone",
+}
+
+var ddlBuiltinTypes = map[string]string{
+	"bigint":                      "int8",
+	"integer":                     "int4",
+	"smallint":                    "int2",
+	"boolean":                     "bool",
+	"character varying":           "varchar",
+	"character":                   "bpchar",
+	"double precision":            "float8",
+	"real":                        "float4",
+	"numeric":                     "numeric",
+	"text":                        "text",
+	"bytea":                       "bytea",
+	"date":                        "date",
+	"timestamp without time zone": "timestamp",
+	"timestamp with time zone":    "timestamptz",
+	"time without time zone":      "time",
+	"time with time zone":         "timetz",
+	"interval":                    "interval",
+	"uuid":                        "uuid",
+	"json":                        "json",
+	"jsonb":                       "jsonb",
+	"inet":                        "inet",
+	"cidr":                        "cidr",
+	"macaddr":                     "macaddr",
+	"money":                       "money",
+	"xml":                         "xml",
+	"oid":                         "oid",
+	"bit":                         "bit",
+	"bit varying":                 "varbit",
+	"tsvector":                    "tsvector",
+	"tsquery":                     "tsquery",
+	"point":                       "point",
+	"name":                        "name",
+}
+
+// ddlType parsed column type
+type ddlType struct {
+	schema  string
+	name    string
+	mod     string
+	array   bool
+	serial  bool
+	builtin bool
+}
+
+// parseType parse a type name with optional modifier and array suffix
+func (p *ddlParser) parseType(schema string) (*ddlType, error) {
+	var words []string
+	typ := &ddlType{}
+	first, err := p.ident()
+	if err != nil {
+		return nil, err
+	}
+	if p.accept(".") {
+		name, err := p.ident()
+		if err != nil {
+			return nil, err
+		}
+		if first != "pg_catalog" {
+			typ.schema, typ.name = first, name
+		}
+		first = name
+	}
+	words = append(words, first)
+	for {
+		t := p.peek()
+		if t.kind == tokIdent && isTypeWord(t.text) {
+			words = append(words, p.next().text)
+			continue
+		}
+		if isWord(t, "(") {
+			toks, err := p.group()
+			if err != nil {
+				return nil, err
+			}
+			typ.mod = "(" + strings.Replace(p.text(toks), " ", "", -1) + ")"
+			continue
+		}
+		if isWord(t, "[") {
+			p.next()
+			p.until(func(t ddlToken) bool { return isWord(t, "]") })
+			if err := p.expect("]"); err != nil {
+				return nil, err
+			}
+			typ.array = true
+			continue
+		}
+		if p.accept("array") {
+			typ.array = true
+			continue
+		}
+		break
+	}
+	if typ.name != "" && len(words) == 1 {
+		return typ, nil
+	}
+	base := strings.Join(words, " ")
+	switch base {
+	case "serial", "serial4", "bigserial", "serial8", "smallserial", "serial2":
+		typ.serial = true
+	}
+	if alias, ok := ddlTypeAliases[base]; ok {
+		base = alias
+	}
+	if _, ok := ddlBuiltinTypes[base]; ok {
+		typ.builtin = true
+		typ.schema = "pg_catalog"
+	} else {
+		typ.schema = schema
+	}
+	typ.name = base
+	return typ, nil
+}
+
+func isTypeWord(w string) bool {
+	switch w {
+	case "varying", "precision", "with", "without", "time", "zone":
+		return true
+	}
+	return false
+}
+
+// format format_type style rendering of the type
+func (t *ddlType) format(defaultSchema string) string {
+	name := t.name
+	if !t.builtin && t.schema != defaultSchema && t.schema != "" {
+		name = t.schema + "." + name
+	}
+	if t.mod != "" {
+		if strings.HasPrefix(name, "timestamp ") || strings.HasPrefix(name, "time ") {
+			sp := strings.Index(name, " ")
+			name = name[:sp] + t.mod + name[sp:]
+		} else {
+			name += t.mod
+		}
+	}
+	if t.array {
+		name += "[]"
+	}
+	return name
+}
+
+// typeName pg_type name of the type, or of the element type of an array
+func (t *ddlType) typeName() string {
+	name := t.name
+	if t.builtin {
+		name = ddlBuiltinTypes[t.name]
+	}
+	return name
+}
+
+func dataTypeOf(ddlType string) string {
+	return strings.Replace(strings.ToUpper(ddlType), "TIMESTAMP WITH TIME ZONE", "TIMESTAMPTZ", -1)
+}
+
+// ddlForeignKey foreign key waiting for its target to be known
+type ddlForeignKey struct {
+	fk         *ForeignKey
+	sourceCols []string
+	targetCols []string
+}
+
+// ddlModel tables and types built up statement by statement
+type ddlModel struct {
+	schema    string
+	tbls      []*Table
+	types     map[tableKey]*ddlType
+	colTypes  map[*Column]*ddlType
+	enums     map[tableKey]*Enum
+	domains   map[tableKey]*Domain
+	composite map[tableKey]*CompositeType
+	fks       map[*Table][]*ddlForeignKey
+	checks    map[*Constraint][]ddlToken
+	parents   map[*Table][]tableKey
+	partOf    map[*Table]tableKey
+	grants    map[*Table]map[ddlGrantKey]map[string]bool
+	owners    map[*Table]string
+}
+
+// ddlGrantKey grantee of privileges on a table, or on one of its columns
+type ddlGrantKey struct {
+	col     *Column
+	grantee string
+}
+
+// privileges of GRANT ALL and of a table owner, and those that apply to columns
+var (
+	ddlTablePrivileges  = []string{"SELECT", "INSERT", "UPDATE", "DELETE", "TRUNCATE", "REFERENCES", "TRIGGER"}
+	ddlColumnPrivileges = []string{"SELECT", "INSERT", "UPDATE", "REFERENCES"}
+)
+
+func newDDLModel() *ddlModel {
+	return &ddlModel{
+		schema:    "public",
+		colTypes:  make(map[*Column]*ddlType),
+		enums:     make(map[tableKey]*Enum),
+		domains:   make(map[tableKey]*Domain),
+		composite: make(map[tableKey]*CompositeType),
+		fks:       make(map[*Table][]*ddlForeignKey),
+		checks:    make(map[*Constraint][]ddlToken),
+		parents:   make(map[*Table][]tableKey),
+		partOf:    make(map[*Table]tableKey),
+		grants:    make(map[*Table]map[ddlGrantKey]map[string]bool),
+		owners:    make(map[*Table]string),
+	}
+}
+
+func (m *ddlModel) table(schema, name string) (*Table, error) {
+	if tbl, found := FindTable(m.tbls, schema, name); found {
+		return tbl, nil
+	}
+	return nil, errors.Errorf("table %s.%s not defined", schema, name)
+}
+
+func (m *ddlModel) dropTable(schema, name string) {
+	var tbls []*Table
+	for _, tbl := range m.tbls {
+		if tbl.Schema != schema || tbl.Name != name {
+			tbls = append(tbls, tbl)
+		}
+	}
+	m.tbls = tbls
+}
+
+// ParseDDL parse the CREATE, ALTER, COMMENT, DROP, GRANT and REVOKE statements of a schema-only dump
+// into tables. Unqualified names belong to public unless search_path is SET.
+// Statements that do not affect the model are ignored.
+func ParseDDL(src string) ([]*Table, error) {
+	m := newDDLModel()
+	if err := m.parse(src); err != nil {
+		return nil, err
+	}
+	if err := m.finish(); err != nil {
+		return nil, err
+	}
+	return m.tbls, nil
+}
+
+// parse add the statements of src to the model, keeping search_path of earlier sources
+func (m *ddlModel) parse(src string) error {
+	toks, err := tokenizeDDL(src)
+	if err != nil {
+		return errors.Wrap(err, "failed to tokenize ddl")
+	}
+	for _, stmt := range splitStatements(toks) {
+		p := &ddlParser{src: src, toks: stmt}
+		if err := m.statement(p); err != nil {
+			line := strings.Count(src[:stmt[0].pos], "\n") + 1
+			return errors.Wrapf(err, "line %d", line)
+		}
+	}
+	return nil
+}
+
+// LoadDDL load table definition of schemas from a dump file, or from all .sql
+// files of a directory in name order, honoring the skip flags of LoadTableDef
+func LoadDDL(path string, schemas []string, skipFlags string) ([]*Table, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read ddl")
+	}
+	files := []string{path}
+	if info.IsDir() {
+		if files, err = filepath.Glob(filepath.Join(path, "*.sql")); err != nil {
+			return nil, errors.Wrap(err, "failed to read ddl")
+		}
+		sort.Strings(files)
+	}
+	m := newDDLModel()
+	for _, f := range files {
+		b, err := ioutil.ReadFile(f)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to read ddl")
+		}
+		if err := m.parse(string(b)); err != nil {
+			return nil, errors.Wrapf(err, "failed to parse %s", f)
+		}
+	}
+	if err := m.finish(); err != nil {
+		return nil, errors.Wrapf(err, "failed to parse %s", path)
+	}
+	return schemaTables(m.tbls, schemas, skipFlags), nil
+}
+
+func (m *ddlModel) statement(p *ddlParser) error {
+	switch {
+	case p.accept("create"):
+		p.accept("or", "replace")
+		p.accept("unlogged")
+		if p.accept("temp") || p.accept("temporary") {
+			return nil
+		}
+		switch {
+		case p.accept("table"):
+			return m.createTable(p)
+		case p.accept("view"), p.accept("recursive", "view"):
+			return m.createView(p, KindView)
+		case p.accept("materialized", "view"):
+			return m.createView(p, KindMaterializedView)
+		case p.accept("type"):
+			return m.createType(p)
+		case p.accept("domain"):
+			return m.createDomain(p)
+		case p.accept("unique", "index"):
+			return m.createIndex(p, true)
+		case p.accept("index"):
+			return m.createIndex(p, false)
+		case p.accept("trigger"), p.accept("constraint", "trigger"):
+			return m.createTrigger(p)
+		case p.accept("policy"):
+			return m.createPolicy(p)
+		}
+	case p.accept("alter", "table"):
+		return m.alterTable(p)
+	case p.accept("alter", "sequence"):
+		return m.alterSequence(p)
+	case p.accept("comment", "on"):
+		return m.comment(p)
+	case p.accept("grant"):
+		return m.grant(p, false)
+	case p.accept("revoke"):
+		return m.grant(p, true)
+	case p.accept("drop"):
+		return m.drop(p)
+	case p.accept("set", "search_path"):
+		if !p.accept("to") {
+			p.accept("=")
+		}
+		if t := p.next(); t.kind == tokIdent || t.kind == tokQuotedIdent || t.kind == tokString {
+			m.schema = t.text
+		}
+	}
+	return nil
+}
+
+func (m *ddlModel) createTable(p *ddlParser) error {
+	p.accept("if", "not", "exists")
+	schema, name, err := p.qualifiedName(m.schema)
+	if err != nil {
+		return err
+	}
+	m.dropTable(schema, name)
+	tbl := &Table{Schema: schema, Name: name, Kind: KindTable}
+	if p.accept("partition", "of") {
+		ps, pn, err := p.qualifiedName(m.schema)
+		if err != nil {
+			return err
+		}
+		tbl.IsPartition = true
+		m.partOf[tbl] = tableKey{Schema: ps, Name: pn}
+	}
+	if isWord(p.peek(), "(") {
+		elems, err := p.group()
+		if err != nil {
+			return err
+		}
+		for _, elem := range splitTop(elems) {
+			if err := m.tableElement(p.sub(elem), tbl); err != nil {
+				return err
+			}
+		}
+	}
+	if tbl.IsPartition {
+		bound := p.until(func(t ddlToken) bool { return isWord(t, "partition") })
+		parent := m.partOf[tbl]
+		if pt, found := FindTable(m.tbls, parent.Schema, parent.Name); found {
+			pt.Partitions = append(pt.Partitions, &Partition{
+				Schema: schema,
+				Name:   name,
+				Bound:  p.bound(bound),
+				Table:  tbl,
+			})
+		}
+	}
+	for !p.done() {
+		switch {
+		case p.accept("inherits"):
+			toks, err := p.group()
+			if err != nil {
+				return err
+			}
+			for _, part := range splitTop(toks) {
+				ps, pn, err := p.sub(part).qualifiedName(m.schema)
+				if err != nil {
+					return err
+				}
+				m.parents[tbl] = append(m.parents[tbl], tableKey{Schema: ps, Name: pn})
+			}
+		case p.accept("partition", "by"):
+			key := p.until(func(t ddlToken) bool {
+				return isWord(t, "with") || isWord(t, "tablespace") || isWord(t, "using")
+			})
+			if len(key) > 0 && isWord(p.peek(), ")") {
+				key = append(key, p.next())
+			}
+			if len(key) < 2 {
+				return errors.Errorf("expected partition key near %q", p.context())
+			}
+			tbl.Kind = KindPartitionedTable
+			tbl.PartitionKey = sql.NullString{String: strings.ToUpper(key[0].text) + " " + p.text(key[1:]), Valid: true}
+		case p.accept("as"):
+			// CREATE TABLE AS, columns come from the query
+			p.i = len(p.toks)
+		default:
+			p.next()
+		}
+	}
+	m.tbls = append(m.tbls, tbl)
+	return nil
+}
+
+func isConstraintWord(t ddlToken) bool {
+	if t.kind != tokIdent {
+		return false
+	}
+	switch t.text {
+	case "constraint", "not", "null", "default", "primary", "unique", "references", "check", "generated", "collate":
+		return true
+	}
+	return false
+}
+
+func (m *ddlModel) tableElement(p *ddlParser, tbl *Table) error {
+	t := p.peek()
+	if t.kind == tokIdent {
+		switch t.text {
+		case "constraint", "primary", "unique", "foreign", "check", "exclude":
+			return m.tableConstraint(p, tbl)
+		case "like":
+			return nil
+		}
+	}
+	return m.columnDef(p, tbl)
+}
+
+func (m *ddlModel) columnDef(p *ddlParser, tbl *Table) error {
+	name, err := p.ident()
+	if err != nil {
+		return err
+	}
+	col := &Column{Name: name}
+	if !p.done() && !isConstraintWord(p.peek()) && !p.peekWords("with", "options") {
+		typ, err := p.parseType(m.schema)
+		if err != nil {
+			return err
+		}
+		m.colTypes[col] = typ
+		if typ.serial {
+			col.NotNull = true
+			seq := tbl.Name + "_" + name + "_seq"
+			col.DefVal = sql.NullString{String: "nextval('" + seq + "'::regclass)", Valid: true}
+			col.SequenceName = sql.NullString{String: tbl.Schema + "." + seq, Valid: true}
+		}
+	}
+	for i, c := range tbl.Columns {
+		if c.Name == name {
+			tbl.Columns = append(tbl.Columns[:i], tbl.Columns[i+1:]...)
+			break
+		}
+	}
+	tbl.Columns = append(tbl.Columns, col)
+	return m.columnConstraints(p, tbl, col)
+}
+
+func (m *ddlModel) columnConstraints(p *ddlParser, tbl *Table, col *Column) error {
+	for !p.done() {
+		var conName string
+		if p.accept("constraint") {
+			var err error
+			if conName, err = p.ident(); err != nil {
+				return err
+			}
+		}
+		switch {
+		case p.accept("not", "null"):
+			col.NotNull = true
+		case p.accept("null"):
+		case p.accept("collate"):
+			if _, _, err := p.qualifiedName(""); err != nil {
+				return err
+			}
+		case p.accept("default"):
+			expr := p.until(isConstraintWord)
+			col.DefVal = sql.NullString{String: p.text(expr), Valid: true}
+		case p.accept("primary", "key"):
+			col.NotNull = true
+			m.primaryKey(tbl, conName, []string{col.Name})
+		case p.accept("unique"):
+			p.accept("nulls", "not", "distinct")
+			p.accept("nulls", "distinct")
+			m.uniqueKey(tbl, conName, []string{col.Name})
+		case p.accept("references"):
+			fk, err := m.references(p, tbl, conName, []string{col.Name})
+			if err != nil {
+				return err
+			}
+			m.fks[tbl] = append(m.fks[tbl], fk)
+		case p.accept("check"):
+			expr, err := p.group()
+			if err != nil {
+				return err
+			}
+			p.accept("no", "inherit")
+			if conName == "" {
+				conName = tbl.Name + "_" + col.Name + "_check"
+			}
+			m.check(p, tbl, conName, expr)
+		case p.accept("generated"):
+			if err := m.generated(p, tbl, col); err != nil {
+				return err
+			}
+		case p.accept("deferrable"), p.accept("not", "deferrable"),
+			p.accept("initially", "deferred"), p.accept("initially", "immediate"):
+		default:
+			return errors.Errorf("unexpected %q in column %s", p.context(), col.Name)
+		}
+	}
+	return nil
+}
+
+func (m *ddlModel) generated(p *ddlParser, tbl *Table, col *Column) error {
+	kind := "ALWAYS"
+	if p.accept("by", "default") {
+		kind = "BY DEFAULT"
+	} else if err := p.expect("always"); err != nil {
+		return err
+	}
+	if err := p.expect("as"); err != nil {
+		return err
+	}
+	if p.accept("identity") {
+		col.IdentityKind = kind
+		col.NotNull = true
+		col.DefVal = sql.NullString{}
+		col.SequenceName = sql.NullString{String: tbl.Schema + "." + tbl.Name + "_" + col.Name + "_seq", Valid: true}
+		if isWord(p.peek(), "(") {
+			if _, err := p.group(); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	expr, err := p.group()
+	if err != nil {
+		return err
+	}
+	p.accept("stored")
+	col.GeneratedExpr = sql.NullString{String: p.text(expr), Valid: true}
+	return nil
+}
+
+func (m *ddlModel) tableConstraint(p *ddlParser, tbl *Table) error {
+	var conName string
+	if p.accept("constraint") {
+		var err error
+		if conName, err = p.ident(); err != nil {
+			return err
+		}
+	}
+	switch {
+	case p.accept("primary", "key"):
+		cols, err := p.identList()
+		if err != nil {
+			return err
+		}
+		for _, c := range tbl.Columns {
+			if hasString(cols, c.Name) {
+				c.NotNull = true
+			}
+		}
+		m.primaryKey(tbl, conName, cols)
+	case p.accept("unique"):
+		p.accept("nulls", "not", "distinct")
+		p.accept("nulls", "distinct")
+		cols, err := p.identList()
+		if err != nil {
+			return err
+		}
+		m.uniqueKey(tbl, conName, cols)
+	case p.accept("foreign", "key"):
+		cols, err := p.identList()
+		if err != nil {
+			return err
+		}
+		if err := p.expect("references"); err != nil {
+			return err
+		}
+		fk, err := m.references(p, tbl, conName, cols)
+		if err != nil {
+			return err
+		}
+		m.fks[tbl] = append(m.fks[tbl], fk)
+	case p.accept("check"):
+		expr, err := p.group()
+		if err != nil {
+			return err
+		}
+		if conName == "" {
+			conName = tbl.Name + "_check"
+		}
+		m.check(p, tbl, conName, expr)
+	case p.accept("exclude"):
+		rest := p.toks[p.i:]
+		if conName == "" {
+			conName = tbl.Name + "_excl"
+		}
+		tbl.Constraints = append(tbl.Constraints, &Constraint{
+			Name:       conName,
+			Type:       ConstraintExclude,
+			Definition: "EXCLUDE " + p.text(rest),
+		})
+		p.i = len(p.toks)
+	default:
+		return errors.Errorf("unexpected %q in table %s", p.context(), tbl.Name)
+	}
+	return nil
+}
+
+func (m *ddlModel) primaryKey(tbl *Table, name string, cols []string) {
+	if name == "" {
+		name = tbl.Name + "_pkey"
+	}
+	for _, c := range tbl.Columns {
+		if hasString(cols, c.Name) {
+			c.IsPrimaryKey = true
+		}
+	}
+	tbl.Indexes = append(tbl.Indexes, &Index{
+		Name:      name,
+		Method:    "btree",
+		IsUnique:  true,
+		IsPrimary: true,
+		Columns:   cols,
+	})
+}
+
+func (m *ddlModel) uniqueKey(tbl *Table, name string, cols []string) {
+	if name == "" {
+		name = tbl.Name + "_" + strings.Join(cols, "_") + "_key"
+	}
+	tbl.Indexes = append(tbl.Indexes, &Index{
+		Name:     name,
+		Method:   "btree",
+		IsUnique: true,
+		Columns:  cols,
+	})
+}
+
+func (m *ddlModel) check(p *ddlParser, tbl *Table, name string, expr []ddlToken) {
+	con := &Constraint{
+		Name:       name,
+		Type:       ConstraintCheck,
+		Definition: "CHECK (" + p.text(expr) + ")",
+	}
+	m.checks[con] = expr
+	tbl.Constraints = append(tbl.Constraints, con)
+}
+
+func (m *ddlModel) references(p *ddlParser, tbl *Table, name string, cols []string) (*ddlForeignKey, error) {
+	ts, tn, err := p.qualifiedName(m.schema)
+	if err != nil {
+		return nil, err
+	}
+	var targetCols []string
+	if isWord(p.peek(), "(") {
+		if targetCols, err = p.identList(); err != nil {
+			return nil, err
+		}
+	}
+	for {
+		switch {
+		case p.accept("match"):
+			p.next()
+		case p.accept("on", "delete"), p.accept("on", "update"):
+			switch {
+			case p.accept("no", "action"), p.accept("cascade"), p.accept("restrict"), p.accept("set", "default"):
+			case p.accept("set", "null"):
+				if isWord(p.peek(), "(") {
+					if _, err := p.group(); err != nil {
+						return nil, err
+					}
+				}
+			}
+		case p.accept("deferrable"), p.accept("not", "deferrable"),
+			p.accept("initially", "deferred"), p.accept("initially", "immediate"),
+			p.accept("not", "valid"):
+		default:
+			if name == "" {
+				name = tbl.Name + "_" + strings.Join(cols, "_") + "_fkey"
+			}
+			return &ddlForeignKey{
+				fk: &ForeignKey{
+					ConstraintName:   name,
+					SourceTableName:  tbl.Name,
+					SourceTable:      tbl,
+					TargetTableName:  tn,
+					SourceSchemaName: tbl.Schema,
+					TargetSchemaName: ts,
+				},
+				sourceCols: cols,
+				targetCols: targetCols,
+			}, nil
+		}
+	}
+}
+
+func (m *ddlModel) createView(p *ddlParser, kind string) error {
+	p.accept("if", "not", "exists")
+	schema, name, err := p.qualifiedName(m.schema)
+	if err != nil {
+		return err
+	}
+	m.dropTable(schema, name)
+	tbl := &Table{Schema: schema, Name: name, Kind: kind}
+	if isWord(p.peek(), "(") {
+		cols, err := p.identList()
+		if err != nil {
+			return err
+		}
+		for _, c := range cols {
+			tbl.Columns = append(tbl.Columns, &Column{Name: c})
+		}
+	} else {
+		for !p.done() && !p.accept("as") {
+			p.next()
+		}
+		p.accept("select")
+		p.accept("distinct")
+		list := p.until(func(t ddlToken) bool { return isWord(t, "from") })
+		for _, item := range splitTop(list) {
+			if name := selectItemName(item); name != "" {
+				tbl.Columns = append(tbl.Columns, &Column{Name: name})
+			}
+		}
+	}
+	m.tbls = append(m.tbls, tbl)
+	return nil
+}
+
+// selectItemName output column name of a select list item, as Postgres names it
+func selectItemName(item []ddlToken) string {
+	n := len(item)
+	if n == 0 || isWord(item[n-1], "*") {
+		return ""
+	}
+	last := item[n-1]
+	if last.kind == tokIdent || last.kind == tokQuotedIdent {
+		if n == 1 || isWord(item[n-2], "as") || isWord(item[n-2], ".") || item[n-2].kind != tokPunct {
+			return last.text
+		}
+	}
+	if n > 1 && item[0].kind == tokIdent && isWord(item[1], "(") {
+		return item[0].text
+	}
+	return "?column?"
+}
+
+func (m *ddlModel) createType(p *ddlParser) error {
+	schema, name, err := p.qualifiedName(m.schema)
+	if err != nil {
+		return err
+	}
+	key := tableKey{Schema: schema, Name: name}
+	if !p.accept("as") {
+		return nil
+	}
+	if p.accept("enum") {
+		toks, err := p.group()
+		if err != nil {
+			return err
+		}
+		e := &Enum{Schema: schema, Name: name}
+		for _, t := range toks {
+			if t.kind == tokString {
+				e.Values = append(e.Values, t.text)
+			}
+		}
+		m.enums[key] = e
+		return nil
+	}
+	if !isWord(p.peek(), "(") {
+		return nil
+	}
+	toks, err := p.group()
+	if err != nil {
+		return err
+	}
+	ct := &CompositeType{Schema: schema, Name: name}
+	for _, attr := range splitTop(toks) {
+		ap := p.sub(attr)
+		an, err := ap.ident()
+		if err != nil {
+			return err
+		}
+		typ, err := ap.parseType(m.schema)
+		if err != nil {
+			return err
+		}
+		ct.Attributes = append(ct.Attributes, &CompositeAttribute{
+			Name:     an,
+			DataType: strings.ToUpper(typ.format("public")),
+		})
+	}
+	m.composite[key] = ct
+	return nil
+}
+
+func (m *ddlModel) createDomain(p *ddlParser) error {
+	schema, name, err := p.qualifiedName(m.schema)
+	if err != nil {
+		return err
+	}
+	p.accept("as")
+	typ, err := p.parseType(m.schema)
+	if err != nil {
+		return err
+	}
+	d := &Domain{Schema: schema, Name: name, BaseType: strings.ToUpper(typ.format("public"))}
+	for !p.done() {
+		switch {
+		case p.accept("constraint"):
+			p.next()
+		case p.accept("not", "null"):
+			d.NotNull = true
+		case p.accept("null"):
+		case p.accept("collate"):
+			p.qualifiedName("")
+		case p.accept("default"):
+			p.until(isConstraintWord)
+		case p.accept("check"):
+			expr, err := p.group()
+			if err != nil {
+				return err
+			}
+			d.Checks = append(d.Checks, "CHECK ("+p.text(expr)+")")
+		default:
+			p.next()
+		}
+	}
+	m.domains[tableKey{Schema: schema, Name: name}] = d
+	return nil
+}
+
+func (m *ddlModel) createIndex(p *ddlParser, unique bool) error {
+	p.accept("concurrently")
+	p.accept("if", "not", "exists")
+	var name string
+	if !p.peekWords("on") {
+		var err error
+		if name, err = p.ident(); err != nil {
+			return err
+		}
+	}
+	if err := p.expect("on"); err != nil {
+		return err
+	}
+	p.accept("only")
+	schema, tn, err := p.qualifiedName(m.schema)
+	if err != nil {
+		return err
+	}
+	tbl, err := m.table(schema, tn)
+	if err != nil {
+		return err
+	}
+	idx := &Index{Method: "btree", IsUnique: unique}
+	if p.accept("using") {
+		idx.Method = p.next().text
+	}
+	elems, err := p.group()
+	if err != nil {
+		return err
+	}
+	var nameParts []string
+	for _, elem := range splitTop(elems) {
+		ep := p.sub(elem)
+		expr := ep.until(func(t ddlToken) bool {
+			return isWord(t, "asc") || isWord(t, "desc") || isWord(t, "nulls") || isWord(t, "collate")
+		})
+		col := p.text(expr)
+		idx.Columns = append(idx.Columns, col)
+		if len(expr) == 1 && (expr[0].kind == tokIdent || expr[0].kind == tokQuotedIdent) {
+			nameParts = append(nameParts, expr[0].text)
+		} else {
+			nameParts = append(nameParts, "expr")
+		}
+	}
+	for !p.done() {
+		if p.accept("where") {
+			idx.Predicate = sql.NullString{String: p.text(p.toks[p.i:]), Valid: true}
+			break
+		}
+		p.next()
+	}
+	if name == "" {
+		name = tbl.Name + "_" + strings.Join(nameParts, "_") + "_idx"
+	}
+	idx.Name = name
+	tbl.Indexes = append(tbl.Indexes, idx)
+	return nil
+}
+
+func (m *ddlModel) createTrigger(p *ddlParser) error {
+	name, err := p.ident()
+	if err != nil {
+		return err
+	}
+	tg := &Trigger{Name: name, Level: "STATEMENT", Enabled: true}
+	switch {
+	case p.accept("before"):
+		tg.Timing = "BEFORE"
+	case p.accept("after"):
+		tg.Timing = "AFTER"
+	case p.accept("instead", "of"):
+		tg.Timing = "INSTEAD OF"
+	default:
+		return errors.Errorf("unexpected %q in trigger %s", p.context(), name)
+	}
+	events := make(map[string]bool)
+	for !p.accept("on") {
+		if p.done() {
+			return errors.Errorf("missing ON in trigger %s", name)
+		}
+		t := p.next()
+		if t.kind == tokIdent {
+			events[strings.ToUpper(t.text)] = true
+		}
+	}
+	for _, e := range []string{"INSERT", "UPDATE", "DELETE", "TRUNCATE"} {
+		if events[e] {
+			tg.Events = append(tg.Events, e)
+		}
+	}
+	schema, tn, err := p.qualifiedName(m.schema)
+	if err != nil {
+		return err
+	}
+	tbl, err := m.table(schema, tn)
+	if err != nil {
+		return err
+	}
+	for !p.done() {
+		switch {
+		case p.accept("for", "each", "row"), p.accept("for", "row"):
+			tg.Level = "ROW"
+		case p.accept("when"):
+			if _, err := p.group(); err != nil {
+				return err
+			}
+		case p.accept("execute", "function"), p.accept("execute", "procedure"):
+			fs, fn, err := p.qualifiedName(m.schema)
+			if err != nil {
+				return err
+			}
+			tg.FunctionSchema, tg.FunctionName = fs, fn
+			p.i = len(p.toks)
+		default:
+			p.next()
+		}
+	}
+	tbl.Triggers = append(tbl.Triggers, tg)
+	return nil
+}
+
+func (m *ddlModel) createPolicy(p *ddlParser) error {
+	name, err := p.ident()
+	if err != nil {
+		return err
+	}
+	if err := p.expect("on"); err != nil {
+		return err
+	}
+	schema, tn, err := p.qualifiedName(m.schema)
+	if err != nil {
+		return err
+	}
+	tbl, err := m.table(schema, tn)
+	if err != nil {
+		return err
+	}
+	pol := &Policy{Name: name, Command: "ALL", Permissive: true}
+	for !p.done() {
+		switch {
+		case p.accept("as", "permissive"):
+		case p.accept("as", "restrictive"):
+			pol.Permissive = false
+		case p.accept("for"):
+			pol.Command = strings.ToUpper(p.next().text)
+		case p.accept("to"):
+			for {
+				t := p.next()
+				role := t.text
+				if t.kind == tokIdent && role == "public" {
+					role = "PUBLIC"
+				}
+				pol.Roles = append(pol.Roles, role)
+				if !p.accept(",") {
+					break
+				}
+			}
+			sort.Strings(pol.Roles)
+		case p.accept("using"):
+			expr, err := p.group()
+			if err != nil {
+				return err
+			}
+			pol.Using = sql.NullString{String: p.text(expr), Valid: true}
+		case p.accept("with", "check"):
+			expr, err := p.group()
+			if err != nil {
+				return err
+			}
+			pol.WithCheck = sql.NullString{String: p.text(expr), Valid: true}
+		default:
+			p.next()
+		}
+	}
+	if len(pol.Roles) == 0 {
+		pol.Roles = []string{"PUBLIC"}
+	}
+	tbl.Policies = append(tbl.Policies, pol)
+	return nil
+}
+
+func (m *ddlModel) alterTable(p *ddlParser) error {
+	p.accept("if", "exists")
+	p.accept("only")
+	schema, name, err := p.qualifiedName(m.schema)
+	if err != nil {
+		return err
+	}
+	tbl, found := FindTable(m.tbls, schema, name)
+	if !found {
+		return nil
+	}
+	for _, action := range splitTop(p.toks[p.i:]) {
+		if err := m.alterAction(p.sub(action), tbl); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *ddlModel) alterAction(p *ddlParser, tbl *Table) error {
+	switch {
+	case p.accept("add"):
+		t := p.peek()
+		if t.kind == tokIdent {
+			switch t.text {
+			case "constraint", "primary", "unique", "foreign", "check", "exclude":
+				return m.tableConstraint(p, tbl)
+			}
+		}
+		p.accept("column")
+		p.accept("if", "not", "exists")
+		return m.columnDef(p, tbl)
+	case p.accept("drop", "constraint"):
+		p.accept("if", "exists")
+		name, err := p.ident()
+		if err != nil {
+			return err
+		}
+		m.dropConstraint(tbl, name)
+	case p.accept("drop"):
+		p.accept("column")
+		p.accept("if", "exists")
+		name, err := p.ident()
+		if err != nil {
+			return err
+		}
+		var cols []*Column
+		for _, c := range tbl.Columns {
+			if c.Name != name {
+				cols = append(cols, c)
+			}
+		}
+		tbl.Columns = cols
+	case p.accept("alter"):
+		p.accept("column")
+		name, err := p.ident()
+		if err != nil {
+			return err
+		}
+		col, found := FindColumnByName([]*Table{tbl}, tbl.Name, name)
+		if !found {
+			return nil
+		}
+		switch {
+		case p.accept("set", "default"):
+			col.DefVal = sql.NullString{String: p.text(p.toks[p.i:]), Valid: true}
+		case p.accept("drop", "default"):
+			col.DefVal = sql.NullString{}
+		case p.accept("set", "not", "null"):
+			col.NotNull = true
+		case p.accept("drop", "not", "null"):
+			col.NotNull = false
+		case p.accept("set", "data", "type"), p.accept("type"):
+			typ, err := p.parseType(m.schema)
+			if err != nil {
+				return err
+			}
+			m.colTypes[col] = typ
+		case p.accept("add", "generated"):
+			return m.generated(p, tbl, col)
+		}
+	case p.accept("rename", "column"):
+		return m.renameColumn(p, tbl)
+	case p.accept("rename", "to"):
+		name, err := p.ident()
+		if err != nil {
+			return err
+		}
+		tbl.Name = name
+	case p.accept("rename"):
+		return m.renameColumn(p, tbl)
+	case p.accept("enable", "row", "level", "security"):
+		tbl.RowSecurity = true
+	case p.accept("disable", "row", "level", "security"):
+		tbl.RowSecurity = false
+	case p.accept("force", "row", "level", "security"):
+		tbl.ForceRowSecurity = true
+	case p.accept("no", "force", "row", "level", "security"):
+		tbl.ForceRowSecurity = false
+	case p.accept("disable", "trigger"), p.accept("enable", "trigger"),
+		p.accept("enable", "always", "trigger"), p.accept("enable", "replica", "trigger"):
+		enabled := !isWord(p.toks[0], "disable")
+		name, err := p.ident()
+		if err != nil {
+			return err
+		}
+		for _, tg := range tbl.Triggers {
+			if tg.Name == name {
+				tg.Enabled = enabled
+			}
+		}
+	case p.accept("owner", "to"):
+		owner, err := p.ident()
+		if err != nil {
+			return err
+		}
+		m.setOwner(tbl, owner)
+	case p.accept("attach", "partition"):
+		schema, name, err := p.qualifiedName(m.schema)
+		if err != nil {
+			return err
+		}
+		part, err := m.table(schema, name)
+		if err != nil {
+			return err
+		}
+		part.IsPartition = true
+		m.partOf[part] = keyOf(tbl)
+		tbl.Partitions = append(tbl.Partitions, &Partition{
+			Schema: schema,
+			Name:   name,
+			Bound:  p.bound(p.toks[p.i:]),
+			Table:  part,
+		})
+	}
+	return nil
+}
+
+func (m *ddlModel) renameColumn(p *ddlParser, tbl *Table) error {
+	from, err := p.ident()
+	if err != nil {
+		return err
+	}
+	if err := p.expect("to"); err != nil {
+		return err
+	}
+	to, err := p.ident()
+	if err != nil {
+		return err
+	}
+	for _, c := range tbl.Columns {
+		if c.Name == from {
+			c.Name = to
+		}
+	}
+	return nil
+}
+
+func (m *ddlModel) dropConstraint(tbl *Table, name string) {
+	var idxs []*Index
+	for _, idx := range tbl.Indexes {
+		if idx.Name != name {
+			idxs = append(idxs, idx)
+		} else if idx.IsPrimary {
+			for _, c := range tbl.Columns {
+				c.IsPrimaryKey = false
+			}
+		}
+	}
+	tbl.Indexes = idxs
+	var cons []*Constraint
+	for _, c := range tbl.Constraints {
+		if c.Name != name {
+			cons = append(cons, c)
+		}
+	}
+	tbl.Constraints = cons
+	var fks []*ddlForeignKey
+	for _, fk := range m.fks[tbl] {
+		if fk.fk.ConstraintName != name {
+			fks = append(fks, fk)
+		}
+	}
+	m.fks[tbl] = fks
+}
+
+func (m *ddlModel) alterSequence(p *ddlParser) error {
+	p.accept("if", "exists")
+	seqSchema, seqName, err := p.qualifiedName(m.schema)
+	if err != nil {
+		return err
+	}
+	for !p.done() {
+		if !p.accept("owned", "by") {
+			p.next()
+			continue
+		}
+		// [schema.]table.column
+		var parts []string
+		for {
+			name, err := p.ident()
+			if err != nil {
+				return err
+			}
+			parts = append(parts, name)
+			if !p.accept(".") {
+				break
+			}
+		}
+		if len(parts) < 2 {
+			return nil
+		}
+		schema := m.schema
+		if len(parts) == 3 {
+			schema = parts[0]
+		}
+		tbl, found := FindTable(m.tbls, schema, parts[len(parts)-2])
+		if !found {
+			return nil
+		}
+		for _, c := range tbl.Columns {
+			if c.Name == parts[len(parts)-1] {
+				c.SequenceName = sql.NullString{String: seqSchema + "." + seqName, Valid: true}
+			}
+		}
+	}
+	return nil
+}
+
+func (m *ddlModel) comment(p *ddlParser) error {
+	var column bool
+	switch {
+	case p.accept("table"), p.accept("view"), p.accept("materialized", "view"):
+	case p.accept("column"):
+		column = true
+	default:
+		return nil
+	}
+	var parts []string
+	for {
+		name, err := p.ident()
+		if err != nil {
+			return err
+		}
+		parts = append(parts, name)
+		if !p.accept(".") {
+			break
+		}
+	}
+	if err := p.expect("is"); err != nil {
+		return err
+	}
+	var comment sql.NullString
+	if t := p.next(); t.kind == tokString {
+		comment = sql.NullString{String: t.text, Valid: true}
+	}
+	schema := m.schema
+	if column {
+		if len(parts) < 2 {
+			return errors.Errorf("invalid column name %s", strings.Join(parts, "."))
+		}
+		if len(parts) == 3 {
+			schema = parts[0]
+		}
+		tbl, found := FindTable(m.tbls, schema, parts[len(parts)-2])
+		if !found {
+			return nil
+		}
+		for _, c := range tbl.Columns {
+			if c.Name == parts[len(parts)-1] {
+				c.Comment = comment
+			}
+		}
+		return nil
+	}
+	if len(parts) == 2 {
+		schema = parts[0]
+	}
+	if tbl, found := FindTable(m.tbls, schema, parts[len(parts)-1]); found {
+		tbl.Comment = comment
+	}
+	return nil
+}
+
+// ddlPrivilege privilege of a GRANT or REVOKE, on the listed columns if any
+type ddlPrivilege struct {
+	names []string
+	cols  []string
+}
+
+// grant apply GRANT or REVOKE of privileges on tables, ignoring other kinds of objects
+func (m *ddlModel) grant(p *ddlParser, revoke bool) error {
+	grantOption := revoke && p.accept("grant", "option", "for")
+	var privs []ddlPrivilege
+	for _, part := range splitTop(p.until(func(t ddlToken) bool { return isWord(t, "on") })) {
+		q := p.sub(part)
+		var words []string
+		for !q.done() && !isWord(q.peek(), "(") {
+			words = append(words, q.next().text)
+		}
+		var priv ddlPrivilege
+		if !q.done() {
+			var err error
+			if priv.cols, err = q.identList(); err != nil {
+				return err
+			}
+		}
+		switch name := strings.Join(words, " "); name {
+		case "all", "all privileges":
+			priv.names = ddlTablePrivileges
+			if len(priv.cols) != 0 {
+				priv.names = ddlColumnPrivileges
+			}
+		default:
+			priv.names = []string{strings.ToUpper(name)}
+		}
+		privs = append(privs, priv)
+	}
+	if err := p.expect("on"); err != nil {
+		return err
+	}
+	var tbls []*Table
+	if p.accept("all", "tables", "in", "schema") {
+		for {
+			schema, err := p.ident()
+			if err != nil {
+				return err
+			}
+			for _, tbl := range m.tbls {
+				if tbl.Schema == schema {
+					tbls = append(tbls, tbl)
+				}
+			}
+			if !p.accept(",") {
+				break
+			}
+		}
+	} else {
+		p.accept("table")
+		for {
+			schema, name, err := p.qualifiedName(m.schema)
+			if err != nil {
+				return err
+			}
+			t := p.peek()
+			if !isWord(t, ",") && !isWord(t, "to") && !isWord(t, "from") {
+				// SEQUENCE, FUNCTION, SCHEMA and other objects
+				return nil
+			}
+			if tbl, found := FindTable(m.tbls, schema, name); found {
+				tbls = append(tbls, tbl)
+			}
+			if !p.accept(",") {
+				break
+			}
+		}
+	}
+	if revoke {
+		if err := p.expect("from"); err != nil {
+			return err
+		}
+	} else if err := p.expect("to"); err != nil {
+		return err
+	}
+	var grantees []string
+	for {
+		p.accept("group")
+		t := p.peek()
+		grantee, err := p.ident()
+		if err != nil {
+			return err
+		}
+		if t.kind == tokIdent && grantee == "public" {
+			grantee = "PUBLIC"
+		}
+		grantees = append(grantees, grantee)
+		if !p.accept(",") {
+			break
+		}
+	}
+	grantable := !revoke && p.accept("with", "grant", "option")
+	for _, tbl := range tbls {
+		for _, priv := range privs {
+			for _, grantee := range grantees {
+				var keys []ddlGrantKey
+				if len(priv.cols) == 0 {
+					keys = append(keys, ddlGrantKey{grantee: grantee})
+				}
+				for _, name := range priv.cols {
+					if col, found := FindColumnByName([]*Table{tbl}, tbl.Name, name); found {
+						keys = append(keys, ddlGrantKey{col: col, grantee: grantee})
+					}
+				}
+				for _, key := range keys {
+					for _, name := range priv.names {
+						if revoke {
+							m.revokePrivilege(tbl, key, name, grantOption)
+						} else {
+							m.grantPrivilege(tbl, key, name, grantable)
+						}
+					}
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func (m *ddlModel) grantPrivilege(tbl *Table, key ddlGrantKey, name string, grantable bool) {
+	if m.grants[tbl] == nil {
+		m.grants[tbl] = make(map[ddlGrantKey]map[string]bool)
+	}
+	privs := m.grants[tbl][key]
+	if privs == nil {
+		privs = make(map[string]bool)
+		m.grants[tbl][key] = privs
+	}
+	privs[name] = privs[name] || grantable
+}
+
+// revokePrivilege revoke a privilege, or only its grant option. Like Postgres, revoking
+// a table privilege also revokes it on every column.
+func (m *ddlModel) revokePrivilege(tbl *Table, key ddlGrantKey, name string, grantOption bool) {
+	for k, privs := range m.grants[tbl] {
+		if k != key && (key.col != nil || k.grantee != key.grantee) {
+			continue
+		}
+		if _, ok := privs[name]; !ok {
+			continue
+		}
+		if grantOption {
+			privs[name] = false
+		} else {
+			delete(privs, name)
+		}
+	}
+}
+
+// setOwner hand the privileges of the previous owner of tbl to owner, or give owner
+// the default privileges of a table owner
+func (m *ddlModel) setOwner(tbl *Table, owner string) {
+	if m.grants[tbl] == nil {
+		m.grants[tbl] = make(map[ddlGrantKey]map[string]bool)
+	}
+	key := ddlGrantKey{grantee: owner}
+	if old, ok := m.owners[tbl]; ok {
+		oldKey := ddlGrantKey{grantee: old}
+		if old != owner {
+			m.grants[tbl][key] = m.grants[tbl][oldKey]
+			delete(m.grants[tbl], oldKey)
+		}
+	} else {
+		privs := make(map[string]bool)
+		for _, name := range ddlTablePrivileges {
+			privs[name] = false
+		}
+		m.grants[tbl][key] = privs
+	}
+	m.owners[tbl] = owner
+}
+
+// tableGrants privileges granted on tbl, grouped and ordered like grantDefSQL
+func (m *ddlModel) tableGrants(tbl *Table) []*Grant {
+	var grants []*Grant
+	for key, privs := range m.grants[tbl] {
+		var column string
+		if key.col != nil {
+			if !hasColumn(tbl, key.col) {
+				continue
+			}
+			column = key.col.Name
+		}
+		var names []string
+		for name := range privs {
+			names = append(names, name)
+		}
+		if len(names) == 0 {
+			continue
+		}
+		sort.Strings(names)
+		for i, name := range names {
+			if privs[name] {
+				names[i] += "*"
+			}
+		}
+		grants = append(grants, &Grant{Column: column, Grantee: key.grantee, Privileges: strings.Join(names, ", ")})
+	}
+	sort.Slice(grants, func(i, j int) bool {
+		if grants[i].Column != grants[j].Column {
+			return grants[i].Column < grants[j].Column
+		}
+		return grants[i].Grantee < grants[j].Grantee
+	})
+	return grants
+}
+
+func hasColumn(tbl *Table, col *Column) bool {
+	for _, c := range tbl.Columns {
+		if c == col {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *ddlModel) drop(p *ddlParser) error {
+	var isType bool
+	switch {
+	case p.accept("table"), p.accept("view"), p.accept("materialized", "view"):
+	case p.accept("type"), p.accept("domain"):
+		isType = true
+	default:
+		return nil
+	}
+	p.accept("if", "exists")
+	names := p.until(func(t ddlToken) bool { return isWord(t, "cascade") || isWord(t, "restrict") })
+	for _, part := range splitTop(names) {
+		schema, name, err := p.sub(part).qualifiedName(m.schema)
+		if err != nil {
+			return err
+		}
+		key := tableKey{Schema: schema, Name: name}
+		if isType {
+			delete(m.enums, key)
+			delete(m.domains, key)
+			delete(m.composite, key)
+			continue
+		}
+		m.dropTable(schema, name)
+	}
+	return nil
+}
+
+// finish resolve types, inheritance, keys and references once all statements are read
+func (m *ddlModel) finish() error {
+	for _, tbl := range m.tbls {
+		for _, col := range tbl.Columns {
+			m.resolveType(col)
+		}
+	}
+	done := make(map[*Table]bool)
+	for _, tbl := range m.tbls {
+		if err := m.inherit(tbl, done, nil); err != nil {
+			return err
+		}
+	}
+	for _, tbl := range m.tbls {
+		for i, col := range tbl.Columns {
+			col.FieldOrdinal = i + 1
+			if col.IsSerial() {
+				col.DDLType = serialDDLType(col.DDLType)
+			}
+			if col.IsPrimaryKey && (col.IsIdentity() || col.IsSerial()) {
+				tbl.AutoGenPk = true
+			}
+		}
+		for _, idx := range tbl.Indexes {
+			if !idx.IsUnique || idx.IsPrimary || idx.Predicate.Valid || !m.plainColumns(tbl, idx.Columns) {
+				continue
+			}
+			if len(idx.Columns) == 1 {
+				for _, c := range tbl.Columns {
+					if c.Name == idx.Columns[0] {
+						c.IsUnique = true
+					}
+				}
+			} else {
+				tbl.UniqueGroups = append(tbl.UniqueGroups, &UniqueGroup{Name: idx.Name, Columns: idx.Columns})
+			}
+		}
+		for _, con := range tbl.Constraints {
+			for _, c := range tbl.Columns {
+				for _, t := range m.checks[con] {
+					if (t.kind == tokIdent || t.kind == tokQuotedIdent) && t.text == c.Name {
+						con.Columns = append(con.Columns, c.Name)
+						break
+					}
+				}
+			}
+		}
+		sort.SliceStable(tbl.Indexes, func(i, j int) bool { return tbl.Indexes[i].Name < tbl.Indexes[j].Name })
+		sort.SliceStable(tbl.UniqueGroups, func(i, j int) bool { return tbl.UniqueGroups[i].Name < tbl.UniqueGroups[j].Name })
+		sort.SliceStable(tbl.Constraints, func(i, j int) bool { return tbl.Constraints[i].Name < tbl.Constraints[j].Name })
+		sort.SliceStable(tbl.Triggers, func(i, j int) bool { return tbl.Triggers[i].Name < tbl.Triggers[j].Name })
+		sort.SliceStable(tbl.Policies, func(i, j int) bool { return tbl.Policies[i].Name < tbl.Policies[j].Name })
+		sort.SliceStable(tbl.Partitions, func(i, j int) bool { return tbl.Partitions[i].Name < tbl.Partitions[j].Name })
+		tbl.Grants = m.tableGrants(tbl)
+	}
+	var fks []*ForeignKey
+	for _, tbl := range m.tbls {
+		for _, dfk := range m.fks[tbl] {
+			targetCols := dfk.targetCols
+			if len(targetCols) == 0 {
+				if target, found := FindTable(m.tbls, dfk.fk.TargetSchemaName, dfk.fk.TargetTableName); found {
+					targetCols = target.PrimaryKeyColNames()
+				}
+			}
+			if len(targetCols) != len(dfk.sourceCols) {
+				return errors.Errorf("foreign key %s of %s: column count mismatch", dfk.fk.ConstraintName, tbl.Name)
+			}
+			for i := range dfk.sourceCols {
+				dfk.fk.Columns = append(dfk.fk.Columns, &ForeignKeyColumn{
+					SourceColName: dfk.sourceCols[i],
+					TargetColName: targetCols[i],
+				})
+			}
+			tbl.ForeingKeys = append(tbl.ForeingKeys, dfk.fk)
+		}
+		sort.SliceStable(tbl.ForeingKeys, func(i, j int) bool {
+			return tbl.ForeingKeys[i].ConstraintName < tbl.ForeingKeys[j].ConstraintName
+		})
+		attachForeignKeys(tbl, tbl.ForeingKeys)
+		fks = append(fks, tbl.ForeingKeys...)
+	}
+	ResolveForeignKeys(m.tbls, fks)
+	ResolveInheritance(m.tbls)
+	sort.SliceStable(m.tbls, func(i, j int) bool {
+		if m.tbls[i].Schema != m.tbls[j].Schema {
+			return m.tbls[i].Schema < m.tbls[j].Schema
+		}
+		return m.tbls[i].Name < m.tbls[j].Name
+	})
+	return nil
+}
+
+func (m *ddlModel) resolveType(col *Column) {
+	typ, ok := m.colTypes[col]
+	if !ok {
+		return
+	}
+	col.DDLType = typ.format("public")
+	col.DataType = dataTypeOf(col.DDLType)
+	col.TypeSchema = typ.schema
+	col.TypeName = typ.typeName()
+	if typ.builtin {
+		return
+	}
+	key := tableKey{Schema: typ.schema, Name: typ.name}
+	if e, ok := m.enums[key]; ok {
+		col.Enum = e
+	} else if d, ok := m.domains[key]; ok {
+		col.Domain = d
+	} else if ct, ok := m.composite[key]; ok {
+		col.Composite = ct
+	}
+}
+
+// inherit prepend columns of parent tables and of the partitioned parent, like Postgres does
+func (m *ddlModel) inherit(tbl *Table, done map[*Table]bool, path []*Table) error {
+	if done[tbl] {
+		return nil
+	}
+	for _, t := range path {
+		if t == tbl {
+			return errors.Errorf("circular inheritance of %s", tbl.Name)
+		}
+	}
+	parents := m.parents[tbl]
+	if key, ok := m.partOf[tbl]; ok {
+		parents = []tableKey{key}
+	}
+	var cols []*Column
+	for _, key := range parents {
+		parent, err := m.table(key.Schema, key.Name)
+		if err != nil {
+			return err
+		}
+		if err := m.inherit(parent, done, append(path, tbl)); err != nil {
+			return err
+		}
+		if _, ok := m.partOf[tbl]; !ok {
+			tbl.Inherits = append(tbl.Inherits, &Inheritance{ParentSchemaName: key.Schema, ParentTableName: key.Name})
+		}
+		for _, pc := range parent.Columns {
+			found := false
+			for _, c := range cols {
+				if c.Name == pc.Name {
+					found = true
+				}
+			}
+			if found {
+				continue
+			}
+			c := *pc
+			c.IsInherited = true
+			c.IsPrimaryKey = false
+			c.IsForeignKey = false
+			c.IsUnique = false
+			c.SequenceName = sql.NullString{}
+			c.Stats = nil
+			cols = append(cols, &c)
+		}
+	}
+	for _, own := range tbl.Columns {
+		merged := false
+		for i, c := range cols {
+			if c.Name == own.Name {
+				if _, typed := m.colTypes[own]; !typed {
+					own.DDLType, own.DataType = c.DDLType, c.DataType
+					own.TypeSchema, own.TypeName = c.TypeSchema, c.TypeName
+					own.Enum, own.Domain, own.Composite = c.Enum, c.Domain, c.Composite
+					own.IsInherited = true
+					own.NotNull = own.NotNull || c.NotNull
+				}
+				cols[i] = own
+				merged = true
+			}
+		}
+		if !merged {
+			cols = append(cols, own)
+		}
+	}
+	tbl.Columns = cols
+	done[tbl] = true
+	return nil
+}
+
+func (m *ddlModel) plainColumns(tbl *Table, names []string) bool {
+	for _, n := range names {
+		found := false
+		for _, c := range tbl.Columns {
+			if c.Name == n {
+				found = true
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
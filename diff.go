@@ -0,0 +1,448 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"text/template"
+)
+
+// kinds of difference between two models
+const (
+	DiffAdded   = "added"
+	DiffRemoved = "removed"
+	DiffChanged = "changed"
+)
+
+var diffColors = map[string]string{
+	DiffAdded:   "#CCFFCC",
+	DiffRemoved: "#FFCCCC",
+	DiffChanged: "#FFFFAA",
+}
+
+// FieldChange attribute that differs between old and new model
+type FieldChange struct {
+	Field string
+	Old   string
+	New   string
+}
+
+// ColumnDiff added, removed or changed column
+type ColumnDiff struct {
+	Name    string
+	Kind    string
+	Changes []*FieldChange
+	Old     *Column `json:"-"`
+	New     *Column `json:"-"`
+}
+
+// Mark +, - or ~ for the kind of difference
+func (d *ColumnDiff) Mark() string {
+	return diffMark(d.Kind)
+}
+
+// Definition type and nullability of the added or removed column
+func (d *ColumnDiff) Definition() string {
+	c := d.New
+	if c == nil {
+		c = d.Old
+	}
+	return columnAttrs(c)[0][1] + " " + columnAttrs(c)[1][1]
+}
+
+// ForeignKeyDiff added, removed or changed foreign key
+type ForeignKeyDiff struct {
+	Name    string
+	Kind    string
+	Changes []*FieldChange
+	Old     *ForeignKey `json:"-"`
+	New     *ForeignKey `json:"-"`
+}
+
+// Mark +, - or ~ for the kind of difference
+func (d *ForeignKeyDiff) Mark() string {
+	return diffMark(d.Kind)
+}
+
+// Definition source and target columns of the added or removed foreign key
+func (d *ForeignKeyDiff) Definition() string {
+	fk := d.New
+	if fk == nil {
+		fk = d.Old
+	}
+	attrs := foreignKeyAttrs(fk)
+	return attrs[0][1] + " -> " + attrs[1][1]
+}
+
+// TableDiff added, removed or changed table
+type TableDiff struct {
+	Schema      string
+	Name        string
+	Kind        string
+	Changes     []*FieldChange
+	Columns     []*ColumnDiff
+	ForeignKeys []*ForeignKeyDiff
+}
+
+// Mark +, - or ~ for the kind of difference
+func (d *TableDiff) Mark() string {
+	return diffMark(d.Kind)
+}
+
+// FullName schema qualified table name
+func (d *TableDiff) FullName() string {
+	return d.Schema + "." + d.Name
+}
+
+// TypeDiff added, removed or changed enum, domain or composite type
+type TypeDiff struct {
+	Schema  string
+	Name    string
+	Type    string
+	Kind    string
+	Changes []*FieldChange
+}
+
+// Mark +, - or ~ for the kind of difference
+func (d *TypeDiff) Mark() string {
+	return diffMark(d.Kind)
+}
+
+// SchemaDiff differences between two models
+type SchemaDiff struct {
+	Tables []*TableDiff
+	Types  []*TypeDiff
+}
+
+// IsEmpty check if the models are the same
+func (d *SchemaDiff) IsEmpty() bool {
+	return len(d.Tables) == 0 && len(d.Types) == 0
+}
+
+func diffMark(kind string) string {
+	switch kind {
+	case DiffAdded:
+		return "+"
+	case DiffRemoved:
+		return "-"
+	}
+	return "~"
+}
+
+func columnAttrs(c *Column) [][2]string {
+	if c == nil {
+		return [][2]string{{"type", ""}, {"nullability", ""}, {"default", ""}, {"comment", ""}}
+	}
+	null := "NULL"
+	if c.NotNull {
+		null = "NOT NULL"
+	}
+	def := c.DefVal.String
+	if c.IsGenerated() {
+		def = "GENERATED " + c.GeneratedExpr.String
+	}
+	return [][2]string{{"type", c.DataType}, {"nullability", null}, {"default", def}, {"comment", c.Comment.String}}
+}
+
+func foreignKeyAttrs(fk *ForeignKey) [][2]string {
+	if fk == nil {
+		return [][2]string{{"columns", ""}, {"references", ""}}
+	}
+	return [][2]string{
+		{"columns", "(" + strings.Join(fk.SourceColNames(), ", ") + ")"},
+		{"references", fk.TargetSchemaName + "." + fk.TargetTableName + " (" + strings.Join(fk.TargetColNames(), ", ") + ")"},
+	}
+}
+
+func tableAttrs(t *Table) [][2]string {
+	if t == nil {
+		return [][2]string{{"kind", ""}, {"comment", ""}}
+	}
+	return [][2]string{{"kind", t.KindName()}, {"comment", t.Comment.String}}
+}
+
+// diffAttrs changes between attributes of the same field order
+func diffAttrs(old, new [][2]string) []*FieldChange {
+	var changes []*FieldChange
+	for i := range new {
+		if old[i][1] != new[i][1] {
+			changes = append(changes, &FieldChange{Field: new[i][0], Old: old[i][1], New: new[i][1]})
+		}
+	}
+	return changes
+}
+
+// blankAttrs attributes with the fields of attrs and no values, for a missing side
+func blankAttrs(attrs [][2]string) [][2]string {
+	blank := make([][2]string, len(attrs))
+	for i, a := range attrs {
+		blank[i][0] = a[0]
+	}
+	return blank
+}
+
+func diffKind(oldExists, newExists bool) string {
+	switch {
+	case !oldExists:
+		return DiffAdded
+	case !newExists:
+		return DiffRemoved
+	}
+	return DiffChanged
+}
+
+func diffColumns(old, new *Table) []*ColumnDiff {
+	var diffs []*ColumnDiff
+	find := func(tbl *Table, name string) *Column {
+		if tbl == nil {
+			return nil
+		}
+		for _, c := range tbl.Columns {
+			if c.Name == name {
+				return c
+			}
+		}
+		return nil
+	}
+	if old != nil {
+		for _, oc := range old.Columns {
+			if find(new, oc.Name) == nil {
+				diffs = append(diffs, &ColumnDiff{
+					Name:    oc.Name,
+					Kind:    DiffRemoved,
+					Changes: diffAttrs(columnAttrs(oc), columnAttrs(nil)),
+					Old:     oc,
+				})
+			}
+		}
+	}
+	if new != nil {
+		for _, nc := range new.Columns {
+			oc := find(old, nc.Name)
+			changes := diffAttrs(columnAttrs(oc), columnAttrs(nc))
+			if oc != nil && len(changes) == 0 {
+				continue
+			}
+			diffs = append(diffs, &ColumnDiff{
+				Name:    nc.Name,
+				Kind:    diffKind(oc != nil, true),
+				Changes: changes,
+				Old:     oc,
+				New:     nc,
+			})
+		}
+	}
+	return diffs
+}
+
+func diffForeignKeys(old, new *Table) []*ForeignKeyDiff {
+	fks := make(map[string][2]*ForeignKey)
+	var names []string
+	collect := func(tbl *Table, side int) {
+		if tbl == nil {
+			return
+		}
+		for _, fk := range tbl.ForeingKeys {
+			pair, ok := fks[fk.ConstraintName]
+			if !ok {
+				names = append(names, fk.ConstraintName)
+			}
+			pair[side] = fk
+			fks[fk.ConstraintName] = pair
+		}
+	}
+	collect(old, 0)
+	collect(new, 1)
+	sort.Strings(names)
+
+	var diffs []*ForeignKeyDiff
+	for _, name := range names {
+		pair := fks[name]
+		changes := diffAttrs(foreignKeyAttrs(pair[0]), foreignKeyAttrs(pair[1]))
+		if pair[0] != nil && pair[1] != nil && len(changes) == 0 {
+			continue
+		}
+		diffs = append(diffs, &ForeignKeyDiff{
+			Name:    name,
+			Kind:    diffKind(pair[0] != nil, pair[1] != nil),
+			Changes: changes,
+			Old:     pair[0],
+			New:     pair[1],
+		})
+	}
+	return diffs
+}
+
+// DiffTables compare tables, columns, comments, foreign keys and user types of two models
+func DiffTables(old, new []*Table) *SchemaDiff {
+	tbls := make(map[tableKey][2]*Table)
+	var keys []tableKey
+	collect := func(l []*Table, side int) {
+		for _, tbl := range l {
+			pair, ok := tbls[keyOf(tbl)]
+			if !ok {
+				keys = append(keys, keyOf(tbl))
+			}
+			pair[side] = tbl
+			tbls[keyOf(tbl)] = pair
+		}
+	}
+	collect(old, 0)
+	collect(new, 1)
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].Schema != keys[j].Schema {
+			return keys[i].Schema < keys[j].Schema
+		}
+		return keys[i].Name < keys[j].Name
+	})
+
+	d := &SchemaDiff{}
+	for _, key := range keys {
+		pair := tbls[key]
+		td := &TableDiff{
+			Schema:      key.Schema,
+			Name:        key.Name,
+			Kind:        diffKind(pair[0] != nil, pair[1] != nil),
+			Changes:     diffAttrs(tableAttrs(pair[0]), tableAttrs(pair[1])),
+			Columns:     diffColumns(pair[0], pair[1]),
+			ForeignKeys: diffForeignKeys(pair[0], pair[1]),
+		}
+		if td.Kind == DiffChanged && len(td.Changes) == 0 && len(td.Columns) == 0 && len(td.ForeignKeys) == 0 {
+			continue
+		}
+		d.Tables = append(d.Tables, td)
+	}
+	d.Types = diffTypes(old, new)
+	return d
+}
+
+// typeAttrs attributes of the user types used by columns of tbls, keyed by type and alias
+func typeAttrs(tbls []*Table) map[[2]string][][2]string {
+	attrs := make(map[[2]string][][2]string)
+	for _, e := range TableEnums(tbls) {
+		attrs[[2]string{"enum", e.Alias()}] = [][2]string{{"values", strings.Join(e.Values, ", ")}}
+	}
+	for _, dm := range TableDomains(tbls) {
+		null := "NULL"
+		if dm.NotNull {
+			null = "NOT NULL"
+		}
+		attrs[[2]string{"domain", dm.Alias()}] = [][2]string{
+			{"base type", dm.BaseType},
+			{"nullability", null},
+			{"checks", strings.Join(dm.Checks, ", ")},
+		}
+	}
+	for _, ct := range TableCompositeTypes(tbls) {
+		attrs[[2]string{"composite", ct.Alias()}] = [][2]string{{"attributes", ct.AttributeList()}}
+	}
+	return attrs
+}
+
+func diffTypes(old, new []*Table) []*TypeDiff {
+	oldAttrs, newAttrs := typeAttrs(old), typeAttrs(new)
+	var keys [][2]string
+	for k := range oldAttrs {
+		keys = append(keys, k)
+	}
+	for k := range newAttrs {
+		if _, ok := oldAttrs[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i][1] != keys[j][1] {
+			return keys[i][1] < keys[j][1]
+		}
+		return keys[i][0] < keys[j][0]
+	})
+
+	var diffs []*TypeDiff
+	for _, k := range keys {
+		o, oldOk := oldAttrs[k]
+		n, newOk := newAttrs[k]
+		if !oldOk {
+			o = blankAttrs(n)
+		}
+		if !newOk {
+			n = blankAttrs(o)
+		}
+		changes := diffAttrs(o, n)
+		if oldOk && newOk && len(changes) == 0 {
+			continue
+		}
+		schema, name := k[1], k[1]
+		if i := strings.Index(k[1], "."); i >= 0 {
+			schema, name = k[1][:i], k[1][i+1:]
+		}
+		diffs = append(diffs, &TypeDiff{
+			Schema:  schema,
+			Name:    name,
+			Type:    k[0],
+			Kind:    diffKind(oldOk, newOk),
+			Changes: changes,
+		})
+	}
+	return diffs
+}
+
+// DiffToText human readable list of differences
+func DiffToText(d *SchemaDiff) ([]byte, error) {
+	tpl, err := template.New("diff").Parse(diffTextTmpl)
+	if err != nil {
+		return nil, err
+	}
+	buf := new(bytes.Buffer)
+	if err := tpl.Execute(buf, d); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// DiffToJSON differences as a JSON document
+func DiffToJSON(d *SchemaDiff) ([]byte, error) {
+	src, err := json.MarshalIndent(d, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(src, '\n'), nil
+}
+
+// DiffToUML PlantUML diagram of the new model and the tables removed from the old one,
+// colored by the kind of difference
+func DiffToUML(d *SchemaDiff, old, new []*Table) ([]byte, error) {
+	kinds := make(map[tableKey]string)
+	for _, td := range d.Tables {
+		kinds[tableKey{Schema: td.Schema, Name: td.Name}] = td.Kind
+	}
+	var tbls []*Table
+	for _, tbl := range new {
+		tbl.Color = diffColors[kinds[keyOf(tbl)]]
+		tbls = append(tbls, tbl)
+	}
+	for _, tbl := range old {
+		if kinds[keyOf(tbl)] == DiffRemoved {
+			tbl.Color = diffColors[DiffRemoved]
+			tbls = append(tbls, tbl)
+		}
+	}
+	entry, err := TableToUMLEntry(tbls)
+	if err != nil {
+		return nil, err
+	}
+	rel, err := ForeignKeyToUMLRelation(tbls)
+	if err != nil {
+		return nil, err
+	}
+	src := []byte("@startuml\nset namespaceSeparator none\n")
+	src = append(src, entry...)
+	src = append(src, rel...)
+	src = append(src, []byte("legend right\n")...)
+	for _, kind := range []string{DiffAdded, DiffRemoved, DiffChanged} {
+		src = append(src, []byte(fmt.Sprintf("    <back:%s>    </back> %s\n", diffColors[kind], kind))...)
+	}
+	src = append(src, []byte("endlegend\n@enduml\n")...)
+	return src, nil
+}
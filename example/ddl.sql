@@ -1,3 +1,7 @@
+drop materialized view if exists vendor_product_count;
+drop view if exists customer_order_summary;
+drop table if exists customer_event;
+drop table if exists corporate_customer;
 drop table if exists order_detail_approval;
 drop table if exists order_detail;
 drop table if exists customer_order;
@@ -6,6 +10,9 @@ drop table if exists product;
 drop table if exists vendor_address;
 drop table if exists vendor;
 drop table if exists customer;
+drop type if exists delivery_method;
+drop domain if exists phone_number;
+drop type if exists postal_address;
 
 
 create table customer (
@@ -22,10 +29,20 @@ COMMENT ON COLUMN customer.zip_code IS 'Customer Zip Code';
 COMMENT ON COLUMN customer.address IS 'Customer Address';
 COMMENT ON COLUMN customer.phone_number IS 'Customer Phone Number';
 
+create domain phone_number as text not null
+  check (value ~ '^[0-9-]+$');
+
+create type postal_address as (
+  zip_code text
+  , line1 text
+  , line2 text
+);
+
 create table vendor (
   id bigserial primary key
   , name text not null
-  , phone_number text not null
+  , phone_number phone_number
+  , billing_address postal_address
 );
 
 create table vendor_address (
@@ -46,6 +63,7 @@ create table product (
   , category text not null
   , FOREIGN KEY(vendor_id) REFERENCES vendor (id)
 );
+create index product_lower_name_idx on product (lower(name)) where country = 'JP';
 
 create table sku (
   id bigserial primary key
@@ -56,12 +74,24 @@ create table sku (
   , sales_unit_price numeric not null
   , purchase_unit_price numeric not null
   , FOREIGN KEY(product_id) REFERENCES product (id)
+  , CONSTRAINT sku_price_check CHECK (sales_unit_price >= purchase_unit_price)
+  , CONSTRAINT sku_product_color_size_key UNIQUE (product_id, color, size)
 );
 
+create type delivery_method as enum ('standard', 'express', 'pickup');
+
+create or replace function set_ordered_at() returns trigger as $$
+begin
+  new.ordered_at := now();
+  return new;
+end;
+$$ language plpgsql;
+COMMENT ON FUNCTION set_ordered_at() IS 'Stamp order time on insert';
+
 create table customer_order (
   id bigserial primary key
   , customer_id bigint not null
-  , delivery_method text not null
+  , delivery_method delivery_method not null
   , shipping_address text not null
   , payment_method text not null
   , total_price numeric not null
@@ -69,6 +99,13 @@ create table customer_order (
   , ordered_at timestamp with time zone not null
   , FOREIGN KEY(customer_id) REFERENCES customer (id)
 );
+create trigger customer_order_ordered_at
+  before insert on customer_order
+  for each row execute procedure set_ordered_at();
+alter table customer_order enable row level security;
+create policy customer_order_owner on customer_order for select
+  using (customer_id = current_setting('app.customer_id')::bigint);
+grant select on customer_order to public;
 
 create table order_detail (
   id bigserial not null
@@ -84,10 +121,50 @@ create table order_detail (
 );
 
 create table order_detail_approval (
-  order_detail_id bigint not null
+  id bigint generated always as identity
+  , order_detail_id bigint not null
   , customer_order_id bigint not null
   , operator_id bigint not null 
-  , approved_at timestamp with time zone not null
+  , approved_at timestamp with time zone not null default now()
+  , approval_key text generated always as (order_detail_id::text || '-' || customer_order_id::text) stored
   , PRIMARY KEY(order_detail_id, customer_order_id)
   , FOREIGN KEY(order_detail_id, customer_order_id) REFERENCES order_detail (id, customer_order_id)
-)
+);
+
+create view customer_order_summary as
+select
+  c.id as customer_id
+  , c.name
+  , count(o.id) as order_count
+  , sum(o.total_price) as total_price
+from customer c
+left join customer_order o on o.customer_id = c.id
+group by c.id, c.name;
+COMMENT ON VIEW customer_order_summary IS 'Order Summary per Customer';
+
+create materialized view vendor_product_count as
+select
+  v.id as vendor_id
+  , count(p.id) as product_count
+from vendor v
+left join product p on p.vendor_id = v.id
+group by v.id;
+
+create table customer_event (
+  customer_id bigint not null
+  , event_type text not null
+  , created_at timestamp with time zone not null
+  , FOREIGN KEY(customer_id) REFERENCES customer (id)
+) partition by range (created_at);
+COMMENT ON TABLE customer_event IS 'Customer Activity Log';
+
+create table customer_event_2024_01 partition of customer_event
+  for values from ('2024-01-01') to ('2024-02-01');
+create table customer_event_2024_02 partition of customer_event
+  for values from ('2024-02-01') to ('2024-03-01');
+
+create table corporate_customer (
+  company_name text not null
+  , tax_number text not null
+) inherits (customer);
+COMMENT ON TABLE corporate_customer IS 'Corporate Customer Information';
@@ -1,44 +1,171 @@
 package main
 
 import (
+	"context"
+	"database/sql"
 	"io"
 	"log"
 	"os"
+	"os/signal"
     "fmt"
+    "net/url"
     "path/filepath"
+    "regexp"
     "strings"
 	"github.com/alecthomas/kingpin"
 )
 
 var (
-	connStr = kingpin.Arg(
-		"conn", "PostgreSQL connection string in URL format").Required().String()
+	renderCmd = kingpin.Command("render", "generate ER diagram and docs").Default()
+	connStr = renderCmd.Arg(
+		"conn", "PostgreSQL connection string in URL format").String()
+	format = renderCmd.Flag("format", "diagram format: plantuml or mermaid").Default("plantuml").Enum("plantuml", "mermaid")
+	diffCmd = kingpin.Command("diff", "compare two databases or snapshots")
+	diffOld = diffCmd.Arg("old", "connection string or snapshot file of the old schema").Required().String()
+	diffNew = diffCmd.Arg("new", "connection string or snapshot file of the new schema").Required().String()
+	diffFormat = diffCmd.Flag("format", "diff output format: text, json or plantuml").Default("text").Enum("text", "json", "plantuml")
+	ddlPath = kingpin.Flag("ddl", "read schema from a SQL DDL dump file or directory instead of a database").String()
+	fromSnapshot = kingpin.Flag("from_snapshot", "read schema from a JSON snapshot instead of a database").String()
+	snapshotOut  = kingpin.Flag("snapshot", "also write the loaded schema to a JSON snapshot file").String()
 	schemas = kingpin.Flag(
 		"schema", "PostgreSQL schemas name").Default("public").Short('s').Strings()
 	outFile     = kingpin.Flag("output", "output file path").Short('o').String()
     outDir     = kingpin.Flag("output_dir", "output dir path").Short('p').String()
     dbName     = kingpin.Flag("dbname", "dbName for UML").Short('d').String()
-	targetTbls  = kingpin.Flag("table", "target tables").Short('t').Strings()
-	xTargetTbls = kingpin.Flag("exclude", "target tables").Short('x').Strings()
+	targetTbls  = kingpin.Flag("table", "target tables (name or schema.name)").Short('t').Strings()
+	xTargetTbls = kingpin.Flag("exclude", "exclude tables (name or schema.name)").Short('x').Strings()
 	xTblNameSuffix = kingpin.Flag("exclude_suffix", "exclude suffix").Short('f').String()
-	skipFlags   = kingpin.Flag("skip_flags", "exclude suffix").Short('q').String()
+	expandPartitions = kingpin.Flag("expand_partitions", "render partitions as separate entities").Bool()
+	hideInherited = kingpin.Flag("hide_inherited", "hide columns inherited from parent tables").Bool()
+	triggerFunctions = kingpin.Flag("trigger_functions", "render trigger functions attached to tables").Bool()
+	selectRoles = kingpin.Flag("select_roles", "color tables by which of these roles (at most 3) can SELECT them").Strings()
+	stats       = kingpin.Flag("stats", "show row count, size and vacuum/analyze statistics").Bool()
+	heatmap     = kingpin.Flag("heatmap", "color tables by size").Bool()
+	mostCommonVals = kingpin.Flag("most_common_values", "show most common column values with --stats").Bool()
+	role        = kingpin.Flag("role", "role to SET for introspection").String()
+	searchPath  = kingpin.Flag("search_path", "comma separated search_path for introspection").String()
+	jobs        = kingpin.Flag("jobs", "number of connections loading the catalog in parallel").Short('j').Default("1").Int()
+	timeout     = kingpin.Flag("timeout", "abort introspection after this duration, also set as statement_timeout (0 for none)").Default("0s").Duration()
+	skipFlags   = kingpin.Flag("skip_flags", "skip loading: a=policies and privileges, c=check and exclusion constraints, f=foreign keys, i=indexes, p=functions and procedures, t=triggers, v=views and materialized views").Short('q').String()
 )
 
 func main() {
-	kingpin.Parse()
+	cmd := kingpin.Parse()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	go func() {
+		<-interrupt
+		signal.Stop(interrupt)
+		cancel()
+	}()
+	if *timeout > 0 {
+		var cancelTimeout context.CancelFunc
+		ctx, cancelTimeout = context.WithTimeout(ctx, *timeout)
+		defer cancelTimeout()
+	}
 
-	db, err := OpenDB(*connStr)
-	if err != nil {
-		log.Fatal(err)
+	if cmd == diffCmd.FullCommand() {
+		run_diff(ctx)
+		return
+	}
+
+	offline := *ddlPath != "" || *fromSnapshot != ""
+	if *connStr == "" && !offline {
+		log.Fatal("either a connection string, --ddl or --from_snapshot is required")
+	}
+	if *ddlPath != "" && *fromSnapshot != "" {
+		log.Fatal("--ddl and --from_snapshot cannot be used together")
+	}
+	if *format == "mermaid" && (*heatmap || len(*selectRoles) != 0 || *triggerFunctions) {
+		log.Fatal("--heatmap, --select_roles and --trigger_functions are not supported with --format mermaid")
+	}
+	if offline {
+		if *stats || *heatmap || len(*selectRoles) != 0 {
+			log.Fatal("--stats, --heatmap and --select_roles need a database connection")
+		}
+		*skipFlags += "p"
 	}
 
+	var db *sql.DB
+	var tx *sql.Tx
+	var workers []QueryerContext
+	if !offline {
+		var err error
+		db, err = OpenDB(*connStr)
+		if err != nil {
+			log.Fatal(err)
+		}
+		conn, err := OpenSession(ctx, db, *timeout)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer conn.Close()
+		tx, err = BeginSnapshot(ctx, conn, "", *role, *searchPath)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer tx.Rollback()
+		workers = append(workers, tx)
+	}
+	if !offline && *jobs > 1 {
+		snapshot, err := ExportSnapshot(ctx, tx)
+		if err != nil {
+			log.Fatal(err)
+		}
+		for i := 1; i < *jobs; i++ {
+			wconn, err := OpenSession(ctx, db, *timeout)
+			if err != nil {
+				log.Fatal(err)
+			}
+			defer wconn.Close()
+			wtx, err := BeginSnapshot(ctx, wconn, snapshot, *role, *searchPath)
+			if err != nil {
+				log.Fatal(err)
+			}
+			defer wtx.Rollback()
+			workers = append(workers, wtx)
+		}
+	}
+
+    if len(*selectRoles) != 0 {
+        if err := CheckSelectRoles(ctx, tx, *selectRoles); err != nil {
+            log.Fatal(err)
+        }
+    }
+
+    loaded, loadedEnums, err := load_table_def(ctx, workers)
+    if err != nil {
+        log.Fatal(err)
+    }
+    if *snapshotOut != "" {
+        out, err := os.Create(*snapshotOut)
+        if err != nil {
+            log.Fatalf("failed to create snapshot file %s: %s", *snapshotOut, err)
+        }
+        if err := WriteSnapshot(out, *schemas, loaded, loadedEnums); err != nil {
+            log.Fatal(err)
+        }
+        if err := out.Close(); err != nil {
+            log.Fatal(err)
+        }
+    }
+
     if *outDir != "" {
-        static_file_erd(*outDir);
-        static_file_legend(*outDir);
+        if *format == "plantuml" {
+            static_file_erd(*outDir);
+        }
+        var allTbls []*Table
+        var diagramTbls []*Table
 
         var main_src []byte
         main_src = append([]byte("@startuml\n"))
         main_src = append(main_src, []byte("skinparam monochrome true\n")...)
+        if *triggerFunctions {
+            main_src = append(main_src, []byte("allowmixing\n")...)
+        }
         main_src = append(main_src, []byte("!ifndef ERD_INCL\n")...)
         main_src = append(main_src, []byte("!include erd.iuml\n")...)
         main_src = append(main_src, []byte("!endif\n")...)
@@ -51,13 +178,15 @@ func main() {
         rst_src = append([]byte("\n"))
 
         for _, schema := range *schemas {
-            fmt.Fprintln(os.Stdout, "Extract schema: " + schema)
+            fmt.Fprintln(os.Stderr, "Extract schema: " + schema)
             var schemaDir string
             schemaDir = filepath.Join(*outDir, schema)
             os.Mkdir(schemaDir, 0777);
-    		ts, err := LoadTableDef(db, schema, *skipFlags)
-            if err != nil {
-                log.Fatal(err)
+            var ts []*Table
+            for _, tbl := range loaded {
+                if tbl.Schema == schema {
+                    ts = append(ts, tbl)
+                }
             }
             var tbls []*Table
             if len(*targetTbls) != 0 {
@@ -71,12 +200,41 @@ func main() {
             if xTblNameSuffix != nil && len(*xTblNameSuffix) > 0 {
                 tbls = FilterTableSuffix(tbls, *xTblNameSuffix)
             }
+            if !*expandPartitions {
+                tbls = FilterPartitions(tbls)
+            }
+            if *hideInherited {
+                tbls = HideInheritedColumns(tbls)
+            }
+            if *stats || *heatmap {
+                if err := LoadTableStats(ctx, tx, schema, tbls); err != nil {
+                    log.Fatal(err)
+                }
+            }
+            if *stats {
+                if err := LoadColumnStats(ctx, tx, schema, tbls, *mostCommonVals); err != nil {
+                    log.Fatal(err)
+                }
+            }
+            if *heatmap {
+                tbls = ColorBySize(tbls)
+            }
+            if len(*selectRoles) != 0 {
+                if err := LoadSelectRoles(ctx, tx, schema, tbls, *selectRoles); err != nil {
+                    log.Fatal(err)
+                }
+                tbls = ColorBySelectRoles(tbls, *selectRoles)
+                allTbls = append(allTbls, tbls...)
+            }
 
             var schema_src []byte
             var schema_rel_src []byte
             schema_src = append([]byte("@startuml\n"))
             schema_rel_src = append([]byte("\n"))
             schema_src = append(schema_src, []byte("skinparam monochrome true\n")...)
+            if *triggerFunctions {
+                schema_src = append(schema_src, []byte("allowmixing\n")...)
+            }
             schema_src = append(schema_src, []byte("!ifndef ERD_INCL\n")...)
             schema_src = append(schema_src, []byte("!include ../erd.iuml\n")...)
             schema_src = append(schema_src, []byte("!endif\n")...)
@@ -95,8 +253,10 @@ func main() {
                 var outFileTbl string;
                 outFileTbl = filepath.Join(schemaDir, tbl.Name + ".puml")
 
-                if err := write_to_file(outFileTbl, umlTable); err != nil {
-                    log.Fatal(err)
+                if *format == "plantuml" {
+                    if err := write_to_file(outFileTbl, umlTable); err != nil {
+                        log.Fatal(err)
+                    }
                 }
 
                 schema_rel1, global_rel2, err := ForeignKeyToUMLRelation2(tbl)
@@ -107,6 +267,14 @@ func main() {
                 schema_rel_src = append(schema_rel_src, schema_rel1...)
                 main_rel_src = append(main_rel_src, global_rel2...)
 
+                schema_inh1, global_inh2, err := InheritanceToUMLRelation2(tbl)
+                if err != nil {
+                    log.Fatal(err)
+                }
+
+                schema_rel_src = append(schema_rel_src, schema_inh1...)
+                main_rel_src = append(main_rel_src, global_inh2...)
+
                 rstTable, err := TableToRSTTable(tbl)
                 if err != nil {
                     log.Fatal(err)
@@ -114,6 +282,77 @@ func main() {
                 rst_src = append(rst_src, rstTable...)
             }
 
+            if *expandPartitions {
+                part_rel, err := PartitionToUMLRelation(tbls)
+                if err != nil {
+                    log.Fatal(err)
+                }
+                schema_rel_src = append(schema_rel_src, part_rel...)
+            }
+
+            var enums []*Enum
+            for _, e := range loadedEnums {
+                if e.Schema == schema {
+                    enums = append(enums, e)
+                }
+            }
+            enum_src, err := EnumToUMLEntry(enums)
+            if err != nil {
+                log.Fatal(err)
+            }
+            schema_src = append(schema_src, enum_src...)
+
+            var domains []*Domain
+            for _, d := range TableDomains(tbls) {
+                if d.Schema == schema {
+                    domains = append(domains, d)
+                }
+            }
+            var composites []*CompositeType
+            for _, c := range TableCompositeTypes(tbls) {
+                if c.Schema == schema {
+                    composites = append(composites, c)
+                }
+            }
+            type_src, err := TypeToUMLEntry(domains, composites)
+            if err != nil {
+                log.Fatal(err)
+            }
+            schema_src = append(schema_src, type_src...)
+
+            type_rel, err := TypeToUMLRelation(tbls)
+            if err != nil {
+                log.Fatal(err)
+            }
+            schema_rel_src = append(schema_rel_src, type_rel...)
+
+            rstTypes, err := TypeToRST(schema, domains, composites)
+            if err != nil {
+                log.Fatal(err)
+            }
+            rst_src = append(rst_src, rstTypes...)
+
+            if !strings.Contains(*skipFlags, "p") {
+                fns, err := LoadFunctionDef(ctx, tx, schema)
+                if err != nil {
+                    log.Fatal(err)
+                }
+                rstFns, err := FunctionToRST(schema, fns)
+                if err != nil {
+                    log.Fatal(err)
+                }
+                rst_src = append(rst_src, rstFns...)
+            }
+
+            if *triggerFunctions {
+                fn_src, fn_rel, err := TriggerFunctionToUML(tbls)
+                if err != nil {
+                    log.Fatal(err)
+                }
+                schema_src = append(schema_src, fn_src...)
+                schema_rel_src = append(schema_rel_src, fn_rel...)
+            }
+
             schema_src = append(schema_src, schema_rel_src...)
 
             schema_src = append(schema_src, []byte("}\n")...)
@@ -123,9 +362,21 @@ func main() {
             schema_src = append(schema_src, []byte("@enduml\n")...)
 
             var outFileSchema string;
-            outFileSchema = filepath.Join(schemaDir, "_schema.puml")
-            if err := write_to_file(outFileSchema, schema_src); err != nil {
-                log.Fatal(err)
+            if *format == "mermaid" {
+                mermaid_src, err := MermaidDiagram(tbls)
+                if err != nil {
+                    log.Fatal(err)
+                }
+                outFileSchema = filepath.Join(schemaDir, "_schema.mmd")
+                if err := write_to_file(outFileSchema, mermaid_src); err != nil {
+                    log.Fatal(err)
+                }
+                diagramTbls = append(diagramTbls, tbls...)
+            } else {
+                outFileSchema = filepath.Join(schemaDir, "_schema.puml")
+                if err := write_to_file(outFileSchema, schema_src); err != nil {
+                    log.Fatal(err)
+                }
             }
 
             main_src = append(main_src, []byte("!include " + schema + "/_schema.puml\n")...)
@@ -141,9 +392,20 @@ func main() {
         main_src = append(main_src, []byte("@enduml\n")...)
 
         var outFileMain string;
-        outFileMain = filepath.Join(*outDir, "sql-db-" + *dbName + "-er.puml")
-        if err := write_to_file(outFileMain, main_src); err != nil {
-            log.Fatal(err)
+        if *format == "mermaid" {
+            mermaid_src, err := MermaidDiagram(diagramTbls)
+            if err != nil {
+                log.Fatal(err)
+            }
+            outFileMain = filepath.Join(*outDir, "sql-db-" + *dbName + "-er.mmd")
+            if err := write_to_file(outFileMain, mermaid_src); err != nil {
+                log.Fatal(err)
+            }
+        } else {
+            outFileMain = filepath.Join(*outDir, "sql-db-" + *dbName + "-er.puml")
+            if err := write_to_file(outFileMain, main_src); err != nil {
+                log.Fatal(err)
+            }
         }
 
         var outFileRST string;
@@ -152,18 +414,23 @@ func main() {
             log.Fatal(err)
         }
 
-
-    } else {
-        ts, err := LoadTableDefForSchemas(db, *schemas, *skipFlags)
-        if err != nil {
-            log.Fatal(err)
+        var legend []byte
+        if len(*selectRoles) != 0 {
+            legend = SelectRoleLegend(allTbls, *selectRoles)
+        } else if *heatmap {
+            legend = SizeLegend()
+        }
+        if *format == "plantuml" {
+            static_file_legend(*outDir, legend);
         }
 
+
+    } else {
         var tbls []*Table
         if len(*targetTbls) != 0 {
-            tbls = FilterTables(true, ts, *targetTbls)
+            tbls = FilterTables(true, loaded, *targetTbls)
         } else {
-            tbls = ts
+            tbls = loaded
         }
         if len(*xTargetTbls) != 0 {
             tbls = FilterTables(false, tbls, *xTargetTbls)
@@ -171,6 +438,48 @@ func main() {
         if xTblNameSuffix != nil && len(*xTblNameSuffix) > 0 {
             tbls = FilterTableSuffix(tbls, *xTblNameSuffix)
         }
+        if !*expandPartitions {
+            tbls = FilterPartitions(tbls)
+        }
+        if *hideInherited {
+            tbls = HideInheritedColumns(tbls)
+        }
+        if *stats || *heatmap {
+            for _, schema := range *schemas {
+                if err := LoadTableStats(ctx, tx, schema, tbls); err != nil {
+                    log.Fatal(err)
+                }
+                if !*stats {
+                    continue
+                }
+                if err := LoadColumnStats(ctx, tx, schema, tbls, *mostCommonVals); err != nil {
+                    log.Fatal(err)
+                }
+            }
+        }
+        if *heatmap {
+            tbls = ColorBySize(tbls)
+        }
+        if len(*selectRoles) != 0 {
+            for _, schema := range *schemas {
+                if err := LoadSelectRoles(ctx, tx, schema, tbls, *selectRoles); err != nil {
+                    log.Fatal(err)
+                }
+            }
+            tbls = ColorBySelectRoles(tbls, *selectRoles)
+        }
+        if *format == "mermaid" {
+            src, err := MermaidDiagram(tbls)
+            if err != nil {
+                log.Fatal(err)
+            }
+            if *outFile != "" {
+                write_to_file(*outFile, src)
+            } else if _, err := os.Stdout.Write(src); err != nil {
+                log.Fatal(err)
+            }
+            return
+        }
         entry, err := TableToUMLEntry(tbls)
         if err != nil {
             log.Fatal(err)
@@ -179,9 +488,54 @@ func main() {
         if err != nil {
             log.Fatal(err)
         }
+        inhRel, err := InheritanceToUMLRelation(tbls)
+        if err != nil {
+            log.Fatal(err)
+        }
+        rel = append(rel, inhRel...)
+        enumEntry, err := EnumToUMLEntry(loadedEnums)
+        if err != nil {
+            log.Fatal(err)
+        }
+        entry = append(entry, enumEntry...)
+        typeEntry, err := TypeToUMLEntry(TableDomains(tbls), TableCompositeTypes(tbls))
+        if err != nil {
+            log.Fatal(err)
+        }
+        entry = append(entry, typeEntry...)
+        typeRel, err := TypeToUMLRelation(tbls)
+        if err != nil {
+            log.Fatal(err)
+        }
+        rel = append(rel, typeRel...)
+        if *triggerFunctions {
+            fnEntry, fnRel, err := TriggerFunctionToUML(tbls)
+            if err != nil {
+                log.Fatal(err)
+            }
+            entry = append([]byte("allowmixing\n"), entry...)
+            entry = append(entry, fnEntry...)
+            rel = append(rel, fnRel...)
+        }
+        if *expandPartitions {
+            partRel, err := PartitionToUMLRelation(tbls)
+            if err != nil {
+                log.Fatal(err)
+            }
+            rel = append(rel, partRel...)
+        }
         var src []byte
-        src = append([]byte("@startuml\n"), entry...)
+        src = append([]byte("@startuml\nset namespaceSeparator none\n"), entry...)
         src = append(src, rel...)
+        if len(*selectRoles) != 0 {
+            src = append(src, []byte("legend right\n")...)
+            src = append(src, SelectRoleLegend(tbls, *selectRoles)...)
+            src = append(src, []byte("endlegend\n")...)
+        } else if *heatmap {
+            src = append(src, []byte("legend right\n")...)
+            src = append(src, SizeLegend()...)
+            src = append(src, []byte("endlegend\n")...)
+        }
         src = append(src, []byte("@enduml\n")...)
 
         var out io.Writer
@@ -199,15 +553,152 @@ func main() {
     }
 }
 
+func load_table_def(ctx context.Context, workers []QueryerContext) ([]*Table, []*Enum, error) {
+    if *ddlPath != "" {
+        tbls, err := LoadDDL(*ddlPath, *schemas, *skipFlags)
+        if err != nil {
+            return nil, nil, err
+        }
+        return tbls, TableEnums(tbls), nil
+    }
+    if *fromSnapshot != "" {
+        s, err := LoadSnapshot(*fromSnapshot, *schemas, *skipFlags)
+        if err != nil {
+            return nil, nil, err
+        }
+        return s.Tables, s.Enums, nil
+    }
+    tbls, err := LoadTableDefParallel(ctx, workers, *schemas, *skipFlags)
+    if err != nil {
+        return nil, nil, err
+    }
+    var enums []*Enum
+    var seen []string
+    for _, schema := range *schemas {
+        if hasString(seen, schema) {
+            continue
+        }
+        seen = append(seen, schema)
+        es, err := LoadEnumDef(ctx, workers[0], schema)
+        if err != nil {
+            return nil, nil, err
+        }
+        enums = append(enums, es...)
+    }
+    return tbls, MergeEnums(enums, TableEnums(tbls)), nil
+}
+func run_diff(ctx context.Context) {
+    old, err := load_diff_side(ctx, *diffOld)
+    if err != nil {
+        log.Fatal(err)
+    }
+    new, err := load_diff_side(ctx, *diffNew)
+    if err != nil {
+        log.Fatal(err)
+    }
+
+    d := DiffTables(old, new)
+    var src []byte
+    switch *diffFormat {
+    case "json":
+        src, err = DiffToJSON(d)
+    case "plantuml":
+        src, err = DiffToUML(d, old, new)
+    default:
+        src, err = DiffToText(d)
+    }
+    if err != nil {
+        log.Fatal(err)
+    }
+
+    var out io.Writer
+    if *outFile != "" {
+        out, err = os.Create(*outFile)
+        if err != nil {
+            log.Fatalf("failed to create output file %s: %s", *outFile, err)
+        }
+    } else {
+        out = os.Stdout
+    }
+    if _, err := out.Write(src); err != nil {
+        log.Fatal(err)
+    }
+}
+
+// load_diff_side load one side of diff from a snapshot file or a database
+func load_diff_side(ctx context.Context, side string) ([]*Table, error) {
+    var tbls []*Table
+    info, err := os.Stat(side)
+    if err == nil && info.IsDir() {
+        return nil, fmt.Errorf("snapshot %s is a directory", side)
+    }
+    if err != nil && !is_conn_str(side) {
+        if os.IsNotExist(err) {
+            return nil, fmt.Errorf("snapshot file %s not found", side)
+        }
+        return nil, err
+    }
+    if err == nil {
+        s, err := LoadSnapshot(side, *schemas, *skipFlags)
+        if err != nil {
+            return nil, err
+        }
+        tbls = s.Tables
+    } else {
+        db, err := OpenDB(side)
+        if err != nil {
+            return nil, err
+        }
+        defer db.Close()
+        conn, err := OpenSession(ctx, db, *timeout)
+        if err != nil {
+            return nil, err
+        }
+        defer conn.Close()
+        tx, err := BeginSnapshot(ctx, conn, "", *role, *searchPath)
+        if err != nil {
+            return nil, err
+        }
+        defer tx.Rollback()
+        tbls, err = LoadTableDefParallel(ctx, []QueryerContext{tx}, *schemas, *skipFlags)
+        if err != nil {
+            return nil, err
+        }
+    }
+    if len(*targetTbls) != 0 {
+        tbls = FilterTables(true, tbls, *targetTbls)
+    }
+    if len(*xTargetTbls) != 0 {
+        tbls = FilterTables(false, tbls, *xTargetTbls)
+    }
+    if xTblNameSuffix != nil && len(*xTblNameSuffix) > 0 {
+        tbls = FilterTableSuffix(tbls, *xTblNameSuffix)
+    }
+    return tbls, nil
+}
+
+var dsnPattern = regexp.MustCompile(`^\s*[a-z_]+\s*=\s*('(?:[^'\\]|\\.)*'|[^\s']*)(\s+[a-z_]+\s*=\s*('(?:[^'\\]|\\.)*'|[^\s']*))*\s*$`)
+
+// is_conn_str check if s parses as a postgres:// URL or a key=value connection string
+func is_conn_str(s string) bool {
+    if u, err := url.Parse(s); err == nil && (u.Scheme == "postgres" || u.Scheme == "postgresql") {
+        return true
+    }
+    return dsnPattern.MatchString(s)
+}
 
 func static_file_erd(outDir string) (error) {
     var src []byte
     src = append([]byte(
 `!define ERD_INCL
 !define table(x) class x << (T,#FFAAAA) >>
+!define view(x) class x << (V,#AAFFAA) >>
+!define mview(x) class x << (M,#AAAAFF) >>
+!define ptable(x) class x << (P,#FFDDAA) >>
 !define pk(x) <u>x</u>
 hide methods
 hide stereotypes
+set namespaceSeparator none
 `))
 	var outFile string;
 	outFile = filepath.Join(outDir, "erd.iuml")
@@ -215,17 +706,26 @@ hide stereotypes
 	return write_to_file(outFile, src)
 }
 
-func static_file_legend(outDir string) (error) {
+func static_file_legend(outDir string, extra []byte) (error) {
     var src []byte
     src = append([]byte(
 `!define LEGEND_INCL
 legend right
     <b>NN</b> - NOT NULL
     <b>UN</b> - UNIQUE
+    <b>UN1, UN2</b> - member of composite UNIQUE group 1, 2
+    <b>FK</b> - FOREIGN KEY
+    <b>ID</b> - IDENTITY
+    <b>SQ</b> - SERIAL (owned sequence)
+    <b>GEN</b> - GENERATED column, field=expression
     <b>field=value</b> - DEFAULT value
     <b><u>field</u></b> - Primary Key
-endlegend
+    <b>(V)</b> - View
+    <b>(M)</b> - Materialized View
+    <b>(P)</b> - Partitioned Table
 `))
+    src = append(src, extra...)
+    src = append(src, []byte("endlegend\n")...)
 
 	var outFile string;
 	outFile = filepath.Join(outDir, "legend.iuml")
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"text/template"
+
+	"github.com/pkg/errors"
+)
+
+// mermaidWord replace characters Mermaid does not allow in attribute types and names
+func mermaidWord(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			return r
+		case r == '_' || r == '-' || r == '(' || r == ')' || r == '[' || r == ']':
+			return r
+		}
+		return '_'
+	}, s)
+}
+
+// MermaidType data type usable as Mermaid attribute type, which cannot be empty
+func (c *Column) MermaidType() string {
+	if c.DataType == "" {
+		return "unknown"
+	}
+	return mermaidWord(c.DataType)
+}
+
+// MermaidName column name usable as Mermaid attribute name
+func (c *Column) MermaidName() string {
+	return mermaidWord(c.Name)
+}
+
+// MermaidComment composite unique group markers and comment of a column, without
+// double quotes, which Mermaid cannot escape
+func (t *Table) MermaidComment(c *Column) string {
+	var parts []string
+	if m := t.UniqueMarkers(c.Name); m != "" {
+		parts = append(parts, m)
+	}
+	if c.Comment.Valid {
+		parts = append(parts, c.Comment.String)
+	}
+	return strings.Replace(strings.Join(parts, " "), `"`, "'", -1)
+}
+
+// MermaidKeys PK, FK and UK annotations of a column. Members of a composite
+// unique group are not unique on their own and get no UK, see MermaidComment.
+func (t *Table) MermaidKeys(c *Column) string {
+	var keys []string
+	if c.IsPrimaryKey {
+		keys = append(keys, "PK")
+	}
+	if c.IsForeignKey {
+		keys = append(keys, "FK")
+	}
+	if c.IsUnique {
+		keys = append(keys, "UK")
+	}
+	return strings.Join(keys, ", ")
+}
+
+// MermaidRelationship crow's foot relationship of a foreign key, identifying
+// if the source columns are part of the primary key
+func (fk *ForeignKey) MermaidRelationship() string {
+	src := "}o"
+	if fk.SourceCardinality() == "0..1" {
+		src = "|o"
+	}
+	dst := "||"
+	if fk.TargetCardinality() == "0..1" {
+		dst = "o|"
+	}
+	line := "--"
+	for _, c := range fk.Columns {
+		if c.SourceColumn == nil || !c.SourceColumn.IsPrimaryKey {
+			line = ".."
+		}
+	}
+	return src + line + dst
+}
+
+// TableToMermaidEntry Mermaid erDiagram entities
+func TableToMermaidEntry(tbls []*Table) ([]byte, error) {
+	tpl, err := template.New("mermaidEntry").Parse(mermaidEntryTmpl)
+	if err != nil {
+		return nil, err
+	}
+	var src []byte
+	for _, tbl := range tbls {
+		buf := new(bytes.Buffer)
+		if err := tpl.Execute(buf, tbl); err != nil {
+			return nil, errors.Wrapf(err, "failed to execute template: %s", tbl.Name)
+		}
+		src = append(src, buf.Bytes()...)
+	}
+	return src, nil
+}
+
+// ForeignKeyToMermaidRelation Mermaid erDiagram relationships between tbls. Foreign keys
+// to tables that are not in tbls are left out, since Mermaid would add them as empty entities.
+func ForeignKeyToMermaidRelation(tbls []*Table) ([]byte, error) {
+	tpl, err := template.New("mermaidRelation").Parse(mermaidRelationTmpl)
+	if err != nil {
+		return nil, err
+	}
+	var src []byte
+	for _, tbl := range tbls {
+		for _, fk := range tbl.ForeingKeys {
+			if _, found := FindTable(tbls, fk.TargetSchemaName, fk.TargetTableName); !found {
+				continue
+			}
+			buf := new(bytes.Buffer)
+			if err := tpl.Execute(buf, fk); err != nil {
+				return nil, errors.Wrapf(err, "failed to execute template: %s", fk.ConstraintName)
+			}
+			src = append(src, buf.Bytes()...)
+		}
+	}
+	return src, nil
+}
+
+// MermaidDiagram Mermaid erDiagram of tables and their relationships
+func MermaidDiagram(tbls []*Table) ([]byte, error) {
+	entry, err := TableToMermaidEntry(tbls)
+	if err != nil {
+		return nil, err
+	}
+	rel, err := ForeignKeyToMermaidRelation(tbls)
+	if err != nil {
+		return nil, err
+	}
+	src := []byte("erDiagram\n")
+	src = append(src, entry...)
+	src = append(src, rel...)
+	return src, nil
+}
@@ -2,21 +2,25 @@ package main
 
 import (
 	"bytes"
+	"context"
 	"database/sql"
 	"fmt"
-	"html/template"
 	"sort"
 	"strings"
+	"sync"
+	"text/template"
+	"time"
     "os"
-	_ "github.com/lib/pq" // postgres
+	"github.com/lib/pq" // postgres
 	"github.com/pkg/errors"
 )
 
-// Queryer database/sql compatible query interface
-type Queryer interface {
-	Exec(string, ...interface{}) (sql.Result, error)
-	Query(string, ...interface{}) (*sql.Rows, error)
-	QueryRow(string, ...interface{}) *sql.Row
+// QueryerContext database/sql compatible context aware query interface,
+// satisfied by *sql.DB, *sql.Conn and *sql.Tx
+type QueryerContext interface {
+	ExecContext(context.Context, string, ...interface{}) (sql.Result, error)
+	QueryContext(context.Context, string, ...interface{}) (*sql.Rows, error)
+	QueryRowContext(context.Context, string, ...interface{}) *sql.Row
 }
 
 // OpenDB opens database connection
@@ -28,16 +32,221 @@ func OpenDB(connStr string) (*sql.DB, error) {
 	return conn, nil
 }
 
+// OpenSession opens a dedicated connection for introspection,
+// with statement_timeout set on it when timeout is positive
+func OpenSession(ctx context.Context, db *sql.DB, timeout time.Duration) (*sql.Conn, error) {
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to open session")
+	}
+	if timeout > 0 {
+		stmt := fmt.Sprintf("SET statement_timeout = %d", int64(timeout/time.Millisecond))
+		if _, err := conn.ExecContext(ctx, stmt); err != nil {
+			conn.Close()
+			return nil, errors.Wrap(err, "failed to set statement_timeout")
+		}
+	}
+	return conn, nil
+}
+
+// BeginSnapshot begins a READ ONLY REPEATABLE READ transaction on conn so that
+// every catalog query sees one consistent snapshot, optionally switching role and search_path.
+// If snapshot is set the transaction imports it, see ExportSnapshot.
+func BeginSnapshot(ctx context.Context, conn *sql.Conn, snapshot, role, searchPath string) (*sql.Tx, error) {
+	tx, err := conn.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to begin snapshot")
+	}
+	if snapshot != "" {
+		if _, err := tx.ExecContext(ctx, "SET TRANSACTION SNAPSHOT "+pq.QuoteLiteral(snapshot)); err != nil {
+			tx.Rollback()
+			return nil, errors.Wrap(err, "failed to import snapshot")
+		}
+	}
+	if role != "" {
+		if _, err := tx.ExecContext(ctx, "SET LOCAL ROLE "+pq.QuoteIdentifier(role)); err != nil {
+			tx.Rollback()
+			return nil, errors.Wrap(err, "failed to set role")
+		}
+	}
+	if searchPath != "" {
+		var path []string
+		for _, s := range strings.Split(searchPath, ",") {
+			path = append(path, pq.QuoteIdentifier(strings.TrimSpace(s)))
+		}
+		if _, err := tx.ExecContext(ctx, "SET LOCAL search_path TO "+strings.Join(path, ", ")); err != nil {
+			tx.Rollback()
+			return nil, errors.Wrap(err, "failed to set search_path")
+		}
+	}
+	return tx, nil
+}
+
+// ExportSnapshot export the snapshot of tx so that other transactions can share it
+// while tx stays open
+func ExportSnapshot(ctx context.Context, tx *sql.Tx) (string, error) {
+	var snapshot string
+	if err := tx.QueryRowContext(ctx, "SELECT pg_export_snapshot()").Scan(&snapshot); err != nil {
+		return "", errors.Wrap(err, "failed to export snapshot")
+	}
+	return snapshot, nil
+}
+
 // Column postgres columns
 type Column struct {
 	FieldOrdinal int
 	Name         string
 	Comment      sql.NullString
 	DataType     string
+	DDLType      string
 	NotNull      bool
 	IsPrimaryKey bool
 	IsUnique bool
+	IsForeignKey bool
+	IsInherited bool
 	DefVal sql.NullString
+	GeneratedExpr sql.NullString
+	IdentityKind string
+	SequenceName sql.NullString
+	TypeSchema string
+	TypeName string
+	Enum *Enum
+	Domain *Domain
+	Composite *CompositeType
+	Stats *ColumnStats
+}
+
+// ColumnStats column statistics from pg_stats
+type ColumnStats struct {
+	NullFrac       float64
+	NDistinct      float64
+	MostCommonVals []string
+}
+
+// NullPercent null fraction in percent
+func (s *ColumnStats) NullPercent() string {
+	return fmt.Sprintf("%.1f%%", s.NullFrac*100)
+}
+
+// Distinct number of distinct values or their ratio to rows
+func (s *ColumnStats) Distinct() string {
+	switch {
+	case s.NDistinct == -1:
+		return "unique"
+	case s.NDistinct < 0:
+		return fmt.Sprintf("%.1f%% of rows", -s.NDistinct*100)
+	}
+	return fmt.Sprintf("%.0f", s.NDistinct)
+}
+
+// MostCommonValueList most common values quoted for csv-table
+func (s *ColumnStats) MostCommonValueList() string {
+	return csvEscape(strings.Join(s.MostCommonVals, ", "))
+}
+
+// csvEscape double quotes in a csv-table cell
+func csvEscape(s string) string {
+	return strings.Replace(s, `"`, `""`, -1)
+}
+
+// rstFuncs template functions of reStructuredText templates
+var rstFuncs = template.FuncMap{"csv": csvEscape}
+
+// IsIdentity check if column is an identity column
+func (c *Column) IsIdentity() bool {
+	return c.IdentityKind != ""
+}
+
+// IsGenerated check if column is a generated column
+func (c *Column) IsGenerated() bool {
+	return c.GeneratedExpr.Valid
+}
+
+// IsSerial check if column is filled from an owned sequence without identity
+func (c *Column) IsSerial() bool {
+	return c.SequenceName.Valid && !c.IsIdentity()
+}
+
+// UserType name of the enum, domain or composite type of the column
+func (c *Column) UserType() string {
+	switch {
+	case c.Enum != nil:
+		return c.Enum.Alias()
+	case c.Domain != nil:
+		return c.Domain.Alias()
+	case c.Composite != nil:
+		return c.Composite.Alias()
+	}
+	return ""
+}
+
+// EnumValueList allowed values of an enum column
+func (c *Column) EnumValueList() string {
+	if c.Enum == nil {
+		return ""
+	}
+	return strings.Join(c.Enum.Values, ", ")
+}
+
+// Enum postgres enum type
+type Enum struct {
+	Schema string
+	Name   string
+	Values []string
+}
+
+// Alias schema qualified PlantUML alias
+func (e *Enum) Alias() string {
+	return umlAlias(e.Schema, e.Name)
+}
+
+// Domain postgres domain type
+type Domain struct {
+	Schema   string
+	Name     string
+	BaseType string
+	NotNull  bool
+	Checks   []string
+}
+
+// Alias schema qualified PlantUML alias
+func (d *Domain) Alias() string {
+	return umlAlias(d.Schema, d.Name)
+}
+
+// CompositeAttribute attribute of a composite type
+type CompositeAttribute struct {
+	Name     string
+	DataType string
+}
+
+// CompositeType postgres composite type
+type CompositeType struct {
+	Schema     string
+	Name       string
+	Attributes []*CompositeAttribute
+}
+
+// Alias schema qualified PlantUML alias
+func (t *CompositeType) Alias() string {
+	return umlAlias(t.Schema, t.Name)
+}
+
+// AttributeList attribute names and types
+func (t *CompositeType) AttributeList() string {
+	var attrs []string
+	for _, a := range t.Attributes {
+		attrs = append(attrs, a.Name+" "+a.DataType)
+	}
+	return strings.Join(attrs, ", ")
+}
+
+// ForeignKeyColumn pair of source and target columns of a foreign key
+type ForeignKeyColumn struct {
+	SourceColName string
+	SourceColumn  *Column
+	TargetColName string
+	TargetColumn  *Column
 }
 
 // ForeignKey foreign key
@@ -46,18 +255,416 @@ type ForeignKey struct {
 	SourceTableName       string
 	SourceTable           *Table
 	TargetTableName       string
-    ConstraintSchemaName  string
-    SourceSchemaName      string
+	TargetTable           *Table
+	Columns               []*ForeignKeyColumn
+	SourceSchemaName      string
+	TargetSchemaName      string
+}
+
+// SourceAlias schema qualified PlantUML alias of the source table
+func (fk *ForeignKey) SourceAlias() string {
+	return umlAlias(fk.SourceSchemaName, fk.SourceTableName)
+}
+
+// TargetAlias schema qualified PlantUML alias of the target table
+func (fk *ForeignKey) TargetAlias() string {
+	return umlAlias(fk.TargetSchemaName, fk.TargetTableName)
+}
+
+// SourceColNames source column names in constraint order
+func (fk *ForeignKey) SourceColNames() []string {
+	var names []string
+	for _, c := range fk.Columns {
+		names = append(names, c.SourceColName)
+	}
+	return names
+}
+
+// TargetColNames target column names in constraint order
+func (fk *ForeignKey) TargetColNames() []string {
+	var names []string
+	for _, c := range fk.Columns {
+		names = append(names, c.TargetColName)
+	}
+	return names
+}
+
+// IsNullable check if any of the source columns accepts null
+func (fk *ForeignKey) IsNullable() bool {
+	for _, c := range fk.Columns {
+		if c.SourceColumn != nil && !c.SourceColumn.NotNull {
+			return true
+		}
+	}
+	return false
+}
+
+// IsOneToOne check if source columns are the primary key or unique
+func (fk *ForeignKey) IsOneToOne() bool {
+	if fk.SourceTable == nil || len(fk.Columns) == 0 {
+		return false
+	}
+	src := fk.SourceColNames()
+	if sameColumns(src, fk.SourceTable.PrimaryKeyColNames()) {
+		return true
+	}
+	for _, g := range fk.SourceTable.UniqueGroups {
+		if sameColumns(src, g.Columns) {
+			return true
+		}
+	}
+	if len(fk.Columns) == 1 {
+		c := fk.Columns[0].SourceColumn
+		return c != nil && c.IsUnique
+	}
+	return false
+}
+
+// sameColumns check if both lists have the same column names in any order
+func sameColumns(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	sorted := append([]string(nil), b...)
+	sort.Strings(sorted)
+	for _, n := range a {
+		if !contains(n, sorted) {
+			return false
+		}
+	}
+	return true
+}
+
+// SourceCardinality cardinality on the referencing side
+func (fk *ForeignKey) SourceCardinality() string {
+	if fk.IsOneToOne() {
+		return "0..1"
+	}
+	return "0..N"
+}
+
+// TargetCardinality cardinality on the referenced side
+func (fk *ForeignKey) TargetCardinality() string {
+	if fk.IsNullable() {
+		return "0..1"
+	}
+	return "1"
+}
+
+// ColumnMapping human readable source to target column mapping
+func (fk *ForeignKey) ColumnMapping() string {
+	src, dst := fk.SourceColNames(), fk.TargetColNames()
+	if len(fk.Columns) == 1 {
+		return src[0] + " = " + dst[0]
+	}
+	return "(" + strings.Join(src, ", ") + ") = (" + strings.Join(dst, ", ") + ")"
+}
+
+// Index postgres index
+type Index struct {
+	Name      string
+	Method    string
+	IsUnique  bool
+	IsPrimary bool
+	Columns   []string
+	Predicate sql.NullString
+}
+
+// ColumnList index columns or expressions
+func (i *Index) ColumnList() string {
+	return strings.Join(i.Columns, ", ")
+}
+
+// constraint types from pg_constraint.contype
+const (
+	ConstraintCheck   = "c"
+	ConstraintExclude = "x"
+)
+
+// UniqueGroup unique constraint or unique index spanning several columns
+type UniqueGroup struct {
+	Name    string
+	Columns []string
+}
+
+// ColumnList comma separated column names
+func (g *UniqueGroup) ColumnList() string {
+	return strings.Join(g.Columns, ", ")
+}
+
+// Constraint check or exclusion constraint
+type Constraint struct {
+	Name       string
+	Type       string
+	Definition string
+	Columns    []string
+}
+
+// TypeName human readable constraint type
+func (c *Constraint) TypeName() string {
+	if c.Type == ConstraintExclude {
+		return "exclude"
+	}
+	return "check"
+}
+
+// ColumnList constrained column names
+func (c *Constraint) ColumnList() string {
+	return strings.Join(c.Columns, ", ")
+}
+
+// Trigger postgres trigger
+type Trigger struct {
+	Name           string
+	Timing         string
+	Events         []string
+	Level          string
+	FunctionSchema string
+	FunctionName   string
+	Enabled        bool
+}
+
+// FunctionAlias PlantUML alias of the trigger function
+func (t *Trigger) FunctionAlias() string {
+	return "fn_" + t.FunctionSchema + "_" + t.FunctionName
+}
+
+// EventList events firing the trigger
+func (t *Trigger) EventList() string {
+	return strings.Join(t.Events, " OR ")
+}
+
+// Function schema qualified trigger function name
+func (t *Trigger) Function() string {
+	return t.FunctionSchema + "." + t.FunctionName
+}
+
+// Function postgres function or procedure
+type Function struct {
+	Schema     string
+	Name       string
+	Kind       string
+	Arguments  string
+	Result     sql.NullString
+	Language   string
+	Volatility string
+	Comment    sql.NullString
+}
+
+// Signature function name with arguments
+func (f *Function) Signature() string {
+	return f.Name + "(" + f.Arguments + ")"
+}
+
+// Policy row level security policy
+type Policy struct {
+	Name       string
+	Command    string
+	Permissive bool
+	Roles      []string
+	Using      sql.NullString
+	WithCheck  sql.NullString
+}
+
+// TypeName permissive or restrictive
+func (p *Policy) TypeName() string {
+	if p.Permissive {
+		return "permissive"
+	}
+	return "restrictive"
+}
+
+// RoleList roles the policy applies to
+func (p *Policy) RoleList() string {
+	return strings.Join(p.Roles, ", ")
+}
+
+// Grant privileges granted to a role on a table or one of its columns
+type Grant struct {
+	Column     string
+	Grantee    string
+	Privileges string
+}
+
+// TableStats table size and maintenance statistics
+type TableStats struct {
+	RowEstimate int64
+	TotalBytes  int64
+	LastVacuum  pq.NullTime
+	LastAnalyze pq.NullTime
+}
+
+// size classes of heat map coloring
+const (
+	mediumTableBytes = 100 * 1024 * 1024
+	hugeTableBytes   = 10 * 1024 * 1024 * 1024
+)
+
+// TotalSize human readable total relation size
+func (s *TableStats) TotalSize() string {
+	units := []string{"bytes", "kB", "MB", "GB", "TB"}
+	size := float64(s.TotalBytes)
+	i := 0
+	for size >= 1024 && i < len(units)-1 {
+		size /= 1024
+		i++
+	}
+	if i == 0 {
+		return fmt.Sprintf("%d %s", s.TotalBytes, units[i])
+	}
+	return fmt.Sprintf("%.1f %s", size, units[i])
+}
+
+// SizeClass small, medium or huge
+func (s *TableStats) SizeClass() string {
+	switch {
+	case s.TotalBytes >= hugeTableBytes:
+		return "huge"
+	case s.TotalBytes >= mediumTableBytes:
+		return "medium"
+	}
+	return "small"
+}
+
+// LastVacuumAt last manual or auto vacuum time
+func (s *TableStats) LastVacuumAt() string {
+	if !s.LastVacuum.Valid {
+		return "never"
+	}
+	return s.LastVacuum.Time.Format("2006-01-02 15:04")
+}
+
+// LastAnalyzeAt last manual or auto analyze time
+func (s *TableStats) LastAnalyzeAt() string {
+	if !s.LastAnalyze.Valid {
+		return "never"
+	}
+	return s.LastAnalyze.Time.Format("2006-01-02 15:04")
+}
+
+// Inheritance parent of a table created with INHERITS
+type Inheritance struct {
+	ParentSchemaName string
+	ParentTableName  string
+	ParentTable      *Table
+}
+
+// ParentAlias schema qualified PlantUML alias of the parent table
+func (i *Inheritance) ParentAlias() string {
+	return umlAlias(i.ParentSchemaName, i.ParentTableName)
+}
+
+// relation kinds from pg_class.relkind
+const (
+	KindTable            = "r"
+	KindView             = "v"
+	KindMaterializedView = "m"
+	KindPartitionedTable = "p"
+)
+
+// Partition partition of a partitioned table
+type Partition struct {
+	Schema string
+	Name   string
+	Bound  string
+	Table  *Table
+}
+
+// Alias schema qualified PlantUML alias
+func (p *Partition) Alias() string {
+	return umlAlias(p.Schema, p.Name)
 }
 
 // Table postgres table
 type Table struct {
 	Schema      string
 	Name        string
+	Kind        string
 	Comment     sql.NullString
 	AutoGenPk   bool
 	Columns     []*Column
 	ForeingKeys []*ForeignKey
+	IsPartition  bool
+	PartitionKey sql.NullString
+	Partitions   []*Partition
+	Inherits     []*Inheritance
+	Indexes      []*Index
+	Constraints  []*Constraint
+	UniqueGroups []*UniqueGroup
+	Triggers     []*Trigger
+	RowSecurity      bool
+	ForceRowSecurity bool
+	Policies         []*Policy
+	Grants           []*Grant
+	SelectRoles      []string
+	Color            string
+	Stats            *TableStats
+}
+
+// IsView check if table is a view
+func (t *Table) IsView() bool {
+	return t.Kind == KindView
+}
+
+// IsMaterializedView check if table is a materialized view
+func (t *Table) IsMaterializedView() bool {
+	return t.Kind == KindMaterializedView
+}
+
+// IsPartitioned check if table is a partitioned table
+func (t *Table) IsPartitioned() bool {
+	return t.Kind == KindPartitionedTable
+}
+
+// FullName schema qualified table name
+func (t *Table) FullName() string {
+	return t.Schema + "." + t.Name
+}
+
+// Alias schema qualified PlantUML alias
+func (t *Table) Alias() string {
+	return umlAlias(t.Schema, t.Name)
+}
+
+// MatchName check if name is the bare or schema qualified table name
+func (t *Table) MatchName(name string) bool {
+	return name == t.Name || name == t.FullName()
+}
+
+// KindName human readable relation kind
+func (t *Table) KindName() string {
+	switch t.Kind {
+	case KindView:
+		return "view"
+	case KindMaterializedView:
+		return "materialized view"
+	case KindPartitionedTable:
+		return "partitioned table"
+	}
+	return "table"
+}
+
+// InheritsNames names of parent tables
+func (t *Table) InheritsNames() string {
+	var names []string
+	for _, i := range t.Inherits {
+		name := i.ParentTableName
+		if i.ParentSchemaName != t.Schema {
+			name = i.ParentSchemaName + "." + name
+		}
+		names = append(names, name)
+	}
+	return strings.Join(names, ", ")
+}
+
+// HasColumnStats check if any column has statistics
+func (t *Table) HasColumnStats() bool {
+	for _, c := range t.Columns {
+		if c.Stats != nil {
+			return true
+		}
+	}
+	return false
 }
 
 // IsCompositePK check if table is composite pk
@@ -74,6 +681,57 @@ func (t *Table) IsCompositePK() bool {
 	return false
 }
 
+// UniqueMarkers UN1, UN2 style markers of the unique groups the column belongs to
+func (t *Table) UniqueMarkers(colName string) string {
+	var markers []string
+	for i, g := range t.UniqueGroups {
+		for _, c := range g.Columns {
+			if c == colName {
+				markers = append(markers, fmt.Sprintf("UN%d", i+1))
+			}
+		}
+	}
+	return strings.Join(markers, " ")
+}
+
+// UniqueGroupMarker UN marker of a unique group, as used by UniqueMarkers
+func (t *Table) UniqueGroupMarker(g *UniqueGroup) string {
+	for i, ug := range t.UniqueGroups {
+		if ug == g {
+			return fmt.Sprintf("UN%d", i+1)
+		}
+	}
+	return ""
+}
+
+// PrimaryKeyColNames primary key column names
+func (t *Table) PrimaryKeyColNames() []string {
+	var names []string
+	for _, c := range t.Columns {
+		if c.IsPrimaryKey {
+			names = append(names, c.Name)
+		}
+	}
+	return names
+}
+
+// References target columns referenced by the given column
+func (t *Table) References(colName string) string {
+	var refs []string
+	for _, fk := range t.ForeingKeys {
+		tblName := fk.TargetTableName
+		if fk.TargetSchemaName != "" && fk.TargetSchemaName != t.Schema {
+			tblName = fk.TargetSchemaName + "." + tblName
+		}
+		for _, c := range fk.Columns {
+			if c.SourceColName == colName {
+				refs = append(refs, tblName+"."+c.TargetColName)
+			}
+		}
+	}
+	return strings.Join(refs, ", ")
+}
+
 func stripCommentSuffix(s string) string {
 	if tok := strings.SplitN(s, "\t", 2); len(tok) == 2 {
 		return tok[0]
@@ -81,20 +739,30 @@ func stripCommentSuffix(s string) string {
 	return s
 }
 
-// FindTableByName find table by name
+// FindTable find table by schema and name
+func FindTable(tbls []*Table, schema, name string) (*Table, bool) {
+	for _, tbl := range tbls {
+		if tbl.Schema == schema && tbl.Name == name {
+			return tbl, true
+		}
+	}
+	return nil, false
+}
+
+// FindTableByName find table by name, either bare or in schema.table form
 func FindTableByName(tbls []*Table, name string) (*Table, bool) {
 	for _, tbl := range tbls {
-		if tbl.Name == name {
+		if tbl.MatchName(name) {
 			return tbl, true
 		}
 	}
 	return nil, false
 }
 
-// FindColumnByName find table by name
+// FindColumnByName find column by table name, either bare or in schema.table form
 func FindColumnByName(tbls []*Table, tableName, colName string) (*Column, bool) {
 	for _, tbl := range tbls {
-		if tbl.Name == tableName {
+		if tbl.MatchName(tableName) {
 			for _, col := range tbl.Columns {
 				if col.Name == colName {
 					return col, true
@@ -105,128 +773,986 @@ func FindColumnByName(tbls []*Table, tableName, colName string) (*Column, bool)
 	return nil, false
 }
 
+func serialDDLType(ddlType string) string {
+	switch ddlType {
+	case "bigint":
+		return "bigserial"
+	case "integer":
+		return "serial"
+	case "smallint":
+		return "smallserial"
+	}
+	return ddlType
+}
+
+// tableKey identifies a table by schema and name
+type tableKey struct {
+	Schema string
+	Name   string
+}
+
+func keyOf(tbl *Table) tableKey {
+	return tableKey{Schema: tbl.Schema, Name: tbl.Name}
+}
+
 // LoadColumnDef load Postgres column definition
-func LoadColumnDef(db Queryer, schema, table string) ([]*Column, error) {
-	colDefs, err := db.Query(columDefSQL, schema, table)
+func LoadColumnDef(ctx context.Context, db QueryerContext, schema, table string) ([]*Column, error) {
+	cols, err := loadColumnDefs(ctx, db, []string{schema}, []string{table})
+	if err != nil {
+		return nil, err
+	}
+	return cols[tableKey{Schema: schema, Name: table}], nil
+}
+
+// loadColumnDefs load columns of all tables in schemas, or only of the given tables if any
+func loadColumnDefs(ctx context.Context, db QueryerContext, schemas, tables []string) (map[tableKey][]*Column, error) {
+	colDefs, err := db.QueryContext(ctx, columDefSQL, pq.Array(schemas), pq.Array(tables))
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to load table def")
 	}
-	var cols []*Column
+	defer colDefs.Close()
+	cols := make(map[tableKey][]*Column)
 	for colDefs.Next() {
+		var key tableKey
 		var c Column
+		var typeType string
+		var enumValues []string
+		var domainBaseType sql.NullString
+		var domainNotNull bool
+		var domainChecks, attrNames, attrTypes []string
 		err := colDefs.Scan(
+			&key.Schema,
+			&key.Name,
 			&c.FieldOrdinal,
 			&c.Name,
 			&c.Comment,
 			&c.DataType,
+			&c.DDLType,
 			&c.NotNull,
 			&c.IsPrimaryKey,
 			&c.IsUnique,
+			&c.IsInherited,
 			&c.DefVal,
+			&c.GeneratedExpr,
+			&c.IdentityKind,
+			&c.SequenceName,
+			&c.TypeSchema,
+			&c.TypeName,
+			&typeType,
+			pq.Array(&enumValues),
+			&domainBaseType,
+			&domainNotNull,
+			pq.Array(&domainChecks),
+			pq.Array(&attrNames),
+			pq.Array(&attrTypes),
 		)
-		c.Comment.String = stripCommentSuffix(c.Comment.String)
 		if err != nil {
 			return nil, errors.Wrap(err, "failed to scan")
 		}
-		cols = append(cols, &c)
+		c.Comment.String = stripCommentSuffix(c.Comment.String)
+		if c.IsSerial() {
+			c.DDLType = serialDDLType(c.DDLType)
+		}
+		switch typeType {
+		case "e":
+			c.Enum = &Enum{
+				Schema: c.TypeSchema,
+				Name:   c.TypeName,
+				Values: enumValues,
+			}
+		case "d":
+			c.Domain = &Domain{
+				Schema:   c.TypeSchema,
+				Name:     c.TypeName,
+				BaseType: domainBaseType.String,
+				NotNull:  domainNotNull,
+				Checks:   domainChecks,
+			}
+		case "c":
+			c.Composite = &CompositeType{
+				Schema: c.TypeSchema,
+				Name:   c.TypeName,
+			}
+			for i := range attrNames {
+				c.Composite.Attributes = append(c.Composite.Attributes, &CompositeAttribute{
+					Name:     attrNames[i],
+					DataType: attrTypes[i],
+				})
+			}
+		}
+		cols[key] = append(cols[key], &c)
+	}
+	if err := colDefs.Err(); err != nil {
+		return nil, errors.Wrap(err, "failed to load table def")
 	}
 	return cols, nil
 }
 
 // LoadForeignKeyDef load Postgres fk definition
-func LoadForeignKeyDef(db Queryer, schema string, tbls []*Table, tbl *Table) ([]*ForeignKey, error) {
-	fkDefs, err := db.Query(fkDefSQL, schema, tbl.Name)
+func LoadForeignKeyDef(ctx context.Context, db QueryerContext, schema string, tbls []*Table, tbl *Table) ([]*ForeignKey, error) {
+	fks, err := loadForeignKeyDefs(ctx, db, []string{schema}, []string{tbl.Name})
+	if err != nil {
+		return nil, err
+	}
+	tblFks := fks[keyOf(tbl)]
+	attachForeignKeys(tbl, tblFks)
+	ResolveForeignKeys(tbls, tblFks)
+	return tblFks, nil
+}
+
+// loadForeignKeyDefs load foreign keys of all tables in schemas, or only of the given tables if any
+func loadForeignKeyDefs(ctx context.Context, db QueryerContext, schemas, tables []string) (map[tableKey][]*ForeignKey, error) {
+	fkDefs, err := db.QueryContext(ctx, fkDefSQL, pq.Array(schemas), pq.Array(tables))
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to load fk def")
 	}
-	var fks []*ForeignKey
+	defer fkDefs.Close()
+	fks := make(map[tableKey][]*ForeignKey)
+	var fk *ForeignKey
 	for fkDefs.Next() {
-		fk := ForeignKey{
-			SourceTableName: tbl.Name,
-			SourceTable:     tbl,
-		}
+		var key tableKey
+		var conName, targetSchema, targetTbl string
+		fc := &ForeignKeyColumn{}
 		err := fkDefs.Scan(
-			&fk.TargetTableName,
-			&fk.ConstraintName,
-			&fk.ConstraintSchemaName,
-            		&fk.SourceSchemaName,
+			&key.Schema,
+			&key.Name,
+			&conName,
+			&targetSchema,
+			&targetTbl,
+			&fc.SourceColName,
+			&fc.TargetColName,
 		)
 		if err != nil {
-			return nil, err
+			return nil, errors.Wrap(err, "failed to scan")
 		}
-		fks = append(fks, &fk)
-	}
-// 	for _, fk := range fks {
-// 		targetTbl, found := FindTableByName(tbls, fk.TargetTableName)
-// 		if !found {
-// 			return nil, errors.Errorf("%s not found", fk.TargetTableName)
-// 		}
-// 		fk.TargetTable = targetTbl
-// 		targetCol, found := FindColumnByName(tbls, fk.TargetTableName, fk.TargetColName)
-// 		if !found {
-// 			return nil, errors.Errorf("%s.%s not found", fk.TargetTableName, fk.TargetColName)
-// 		}
-// 		fk.TargetColumn = targetCol
-// 		sourceCol, found := FindColumnByName(tbls, fk.SourceTableName, fk.SourceColName)
-// 		if !found {
-// 			return nil, errors.Errorf("%s.%s not found", fk.SourceTableName, fk.SourceColName)
-// 		}
-// 		fk.SourceColumn = sourceCol
-// 	}
+		if fk == nil || fk.ConstraintName != conName ||
+			fk.SourceSchemaName != key.Schema || fk.SourceTableName != key.Name {
+			fk = &ForeignKey{
+				ConstraintName:   conName,
+				SourceTableName:  key.Name,
+				TargetTableName:  targetTbl,
+				SourceSchemaName: key.Schema,
+				TargetSchemaName: targetSchema,
+			}
+			fks[key] = append(fks[key], fk)
+		}
+		fk.Columns = append(fk.Columns, fc)
+	}
+	if err := fkDefs.Err(); err != nil {
+		return nil, errors.Wrap(err, "failed to load fk def")
+	}
 	return fks, nil
 }
 
-// LoadTableDefForSchemas load Postgres table definition
-func LoadTableDefForSchemas(db Queryer, schemas []string, skipFlags string) ([]*Table, error) {
-    var tbls []*Table
-	for _, schema := range schemas {
-		tbls2, err := LoadTableDef(db, schema, skipFlags)
-		tbls = append(tbls, tbls2...)
+// attachForeignKeys link foreign keys to their source table and columns
+func attachForeignKeys(tbl *Table, fks []*ForeignKey) {
+	for _, fk := range fks {
+		fk.SourceTable = tbl
+		for _, fc := range fk.Columns {
+			for _, col := range tbl.Columns {
+				if col.Name == fc.SourceColName {
+					col.IsForeignKey = true
+					fc.SourceColumn = col
+				}
+			}
+		}
+	}
+}
+
+// ResolveForeignKeys link foreign keys to target tables and columns found in tbls
+func ResolveForeignKeys(tbls []*Table, fks []*ForeignKey) {
+	index := make(map[tableKey]*Table, len(tbls))
+	for _, tbl := range tbls {
+		index[keyOf(tbl)] = tbl
+	}
+	for _, fk := range fks {
+		fk.TargetTable = index[tableKey{Schema: fk.TargetSchemaName, Name: fk.TargetTableName}]
+		if fk.TargetTable == nil {
+			continue
+		}
+		for _, fc := range fk.Columns {
+			for _, col := range fk.TargetTable.Columns {
+				if col.Name == fc.TargetColName {
+					fc.TargetColumn = col
+				}
+			}
+		}
+	}
+}
+
+// LoadPartitionDef load Postgres partitions of a partitioned table
+func LoadPartitionDef(ctx context.Context, db QueryerContext, schema string, tbls []*Table, tbl *Table) ([]*Partition, error) {
+	parts, err := loadPartitionDefs(ctx, db, []string{schema}, []string{tbl.Name})
+	if err != nil {
+		return nil, err
+	}
+	tblParts := parts[keyOf(tbl)]
+	resolvePartitions(tbls, tblParts)
+	return tblParts, nil
+}
+
+// loadPartitionDefs load partitions of all partitioned tables in schemas, or only of the given tables if any
+func loadPartitionDefs(ctx context.Context, db QueryerContext, schemas, tables []string) (map[tableKey][]*Partition, error) {
+	partDefs, err := db.QueryContext(ctx, partitionDefSQL, pq.Array(schemas), pq.Array(tables))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to load partition def")
+	}
+	defer partDefs.Close()
+	parts := make(map[tableKey][]*Partition)
+	for partDefs.Next() {
+		var key tableKey
+		var p Partition
+		err := partDefs.Scan(
+			&key.Schema,
+			&key.Name,
+			&p.Schema,
+			&p.Name,
+			&p.Bound,
+		)
 		if err != nil {
-            return tbls, err
-        }
+			return nil, errors.Wrap(err, "failed to scan")
+		}
+		parts[key] = append(parts[key], &p)
+	}
+	if err := partDefs.Err(); err != nil {
+		return nil, errors.Wrap(err, "failed to load partition def")
+	}
+	return parts, nil
+}
 
+func resolvePartitions(tbls []*Table, parts []*Partition) {
+	for _, p := range parts {
+		p.Table, _ = FindTable(tbls, p.Schema, p.Name)
+	}
+}
+
+// LoadIndexDef load Postgres index definition
+func LoadIndexDef(ctx context.Context, db QueryerContext, schema string, tbl *Table) ([]*Index, error) {
+	idxs, err := loadIndexDefs(ctx, db, []string{schema}, []string{tbl.Name})
+	if err != nil {
+		return nil, err
+	}
+	return idxs[keyOf(tbl)], nil
+}
+
+// loadIndexDefs load indexes of all tables in schemas, or only of the given tables if any
+func loadIndexDefs(ctx context.Context, db QueryerContext, schemas, tables []string) (map[tableKey][]*Index, error) {
+	idxDefs, err := db.QueryContext(ctx, indexDefSQL, pq.Array(schemas), pq.Array(tables))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to load index def")
+	}
+	defer idxDefs.Close()
+	idxs := make(map[tableKey][]*Index)
+	for idxDefs.Next() {
+		var key tableKey
+		var i Index
+		err := idxDefs.Scan(
+			&key.Schema,
+			&key.Name,
+			&i.Name,
+			&i.Method,
+			&i.IsUnique,
+			&i.IsPrimary,
+			pq.Array(&i.Columns),
+			&i.Predicate,
+		)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to scan")
+		}
+		idxs[key] = append(idxs[key], &i)
+	}
+	if err := idxDefs.Err(); err != nil {
+		return nil, errors.Wrap(err, "failed to load index def")
+	}
+	return idxs, nil
+}
+
+// LoadUniqueDef load Postgres unique constraints and indexes spanning several columns
+func LoadUniqueDef(ctx context.Context, db QueryerContext, schema string, tbl *Table) ([]*UniqueGroup, error) {
+	uniqs, err := loadUniqueDefs(ctx, db, []string{schema}, []string{tbl.Name})
+	if err != nil {
+		return nil, err
+	}
+	return uniqs[keyOf(tbl)], nil
+}
+
+// loadUniqueDefs load unique groups of all tables in schemas, or only of the given tables if any
+func loadUniqueDefs(ctx context.Context, db QueryerContext, schemas, tables []string) (map[tableKey][]*UniqueGroup, error) {
+	uniqDefs, err := db.QueryContext(ctx, uniqueDefSQL, pq.Array(schemas), pq.Array(tables))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to load unique def")
+	}
+	defer uniqDefs.Close()
+	uniqs := make(map[tableKey][]*UniqueGroup)
+	for uniqDefs.Next() {
+		var key tableKey
+		var u UniqueGroup
+		err := uniqDefs.Scan(
+			&key.Schema,
+			&key.Name,
+			&u.Name,
+			pq.Array(&u.Columns),
+		)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to scan")
+		}
+		uniqs[key] = append(uniqs[key], &u)
+	}
+	if err := uniqDefs.Err(); err != nil {
+		return nil, errors.Wrap(err, "failed to load unique def")
+	}
+	return uniqs, nil
+}
+
+// LoadConstraintDef load Postgres check and exclusion constraint definition
+func LoadConstraintDef(ctx context.Context, db QueryerContext, schema string, tbl *Table) ([]*Constraint, error) {
+	cons, err := loadConstraintDefs(ctx, db, []string{schema}, []string{tbl.Name})
+	if err != nil {
+		return nil, err
+	}
+	return cons[keyOf(tbl)], nil
+}
+
+// loadConstraintDefs load check and exclusion constraints of all tables in schemas, or only of the given tables if any
+func loadConstraintDefs(ctx context.Context, db QueryerContext, schemas, tables []string) (map[tableKey][]*Constraint, error) {
+	conDefs, err := db.QueryContext(ctx, constraintDefSQL, pq.Array(schemas), pq.Array(tables))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to load constraint def")
+	}
+	defer conDefs.Close()
+	cons := make(map[tableKey][]*Constraint)
+	for conDefs.Next() {
+		var key tableKey
+		var c Constraint
+		err := conDefs.Scan(
+			&key.Schema,
+			&key.Name,
+			&c.Name,
+			&c.Type,
+			&c.Definition,
+			pq.Array(&c.Columns),
+		)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to scan")
+		}
+		cons[key] = append(cons[key], &c)
+	}
+	if err := conDefs.Err(); err != nil {
+		return nil, errors.Wrap(err, "failed to load constraint def")
+	}
+	return cons, nil
+}
+
+// LoadTriggerDef load Postgres trigger definition
+func LoadTriggerDef(ctx context.Context, db QueryerContext, schema string, tbl *Table) ([]*Trigger, error) {
+	tgs, err := loadTriggerDefs(ctx, db, []string{schema}, []string{tbl.Name})
+	if err != nil {
+		return nil, err
+	}
+	return tgs[keyOf(tbl)], nil
+}
+
+// serverVersion Postgres server_version_num, e.g. 130004
+func serverVersion(ctx context.Context, db QueryerContext) (int, error) {
+	var version int
+	if err := db.QueryRowContext(ctx, serverVersionSQL).Scan(&version); err != nil {
+		return 0, errors.Wrap(err, "failed to load server version")
+	}
+	return version, nil
+}
+
+// minServerVersion oldest Postgres the catalog queries run on: pg_partition_tree
+// and pg_attribute.attgenerated need 12, pg_proc.prokind and pg_index.indnkeyatts 11
+const minServerVersion = 120000
+
+// checkServerVersion check if the catalog queries run on server_version_num version
+func checkServerVersion(version int) error {
+	if version < minServerVersion {
+		return errors.Errorf("planter requires PostgreSQL 12 or later, server version is %d", version)
+	}
+	return nil
+}
+
+// loadTriggerDefs load triggers of all tables in schemas, or only of the given tables if any
+func loadTriggerDefs(ctx context.Context, db QueryerContext, schemas, tables []string) (map[tableKey][]*Trigger, error) {
+	version, err := serverVersion(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+	var filter string
+	if version >= 130000 {
+		filter = triggerParentFilterSQL
+	}
+	tgDefs, err := db.QueryContext(ctx, fmt.Sprintf(triggerDefSQL, filter), pq.Array(schemas), pq.Array(tables))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to load trigger def")
+	}
+	defer tgDefs.Close()
+	tgs := make(map[tableKey][]*Trigger)
+	for tgDefs.Next() {
+		var key tableKey
+		var t Trigger
+		err := tgDefs.Scan(
+			&key.Schema,
+			&key.Name,
+			&t.Name,
+			&t.Timing,
+			pq.Array(&t.Events),
+			&t.Level,
+			&t.FunctionSchema,
+			&t.FunctionName,
+			&t.Enabled,
+		)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to scan")
+		}
+		tgs[key] = append(tgs[key], &t)
+	}
+	if err := tgDefs.Err(); err != nil {
+		return nil, errors.Wrap(err, "failed to load trigger def")
+	}
+	return tgs, nil
+}
+
+// LoadFunctionDef load Postgres function and procedure definition
+func LoadFunctionDef(ctx context.Context, db QueryerContext, schema string) ([]*Function, error) {
+	fnDefs, err := db.QueryContext(ctx, functionDefSQL, schema)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to load function def")
+	}
+	defer fnDefs.Close()
+	var fns []*Function
+	for fnDefs.Next() {
+		f := Function{Schema: schema}
+		err := fnDefs.Scan(
+			&f.Name,
+			&f.Kind,
+			&f.Arguments,
+			&f.Result,
+			&f.Language,
+			&f.Volatility,
+			&f.Comment,
+		)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to scan")
+		}
+		f.Comment.String = stripCommentSuffix(f.Comment.String)
+		fns = append(fns, &f)
+	}
+	if err := fnDefs.Err(); err != nil {
+		return nil, errors.Wrap(err, "failed to load function def")
+	}
+	return fns, nil
+}
+
+// LoadEnumDef load Postgres enum types of schema, including the ones no column uses
+func LoadEnumDef(ctx context.Context, db QueryerContext, schema string) ([]*Enum, error) {
+	enumDefs, err := db.QueryContext(ctx, enumDefSQL, schema)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to load enum def")
+	}
+	defer enumDefs.Close()
+	var enums []*Enum
+	for enumDefs.Next() {
+		e := Enum{Schema: schema}
+		if err := enumDefs.Scan(&e.Name, pq.Array(&e.Values)); err != nil {
+			return nil, errors.Wrap(err, "failed to scan")
+		}
+		enums = append(enums, &e)
+	}
+	if err := enumDefs.Err(); err != nil {
+		return nil, errors.Wrap(err, "failed to load enum def")
+	}
+	return enums, nil
+}
+
+// LoadPolicyDef load Postgres row level security policy definition
+func LoadPolicyDef(ctx context.Context, db QueryerContext, schema string, tbl *Table) ([]*Policy, error) {
+	pols, err := loadPolicyDefs(ctx, db, []string{schema}, []string{tbl.Name})
+	if err != nil {
+		return nil, err
+	}
+	return pols[keyOf(tbl)], nil
+}
+
+// loadPolicyDefs load policies of all tables in schemas, or only of the given tables if any
+func loadPolicyDefs(ctx context.Context, db QueryerContext, schemas, tables []string) (map[tableKey][]*Policy, error) {
+	polDefs, err := db.QueryContext(ctx, policyDefSQL, pq.Array(schemas), pq.Array(tables))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to load policy def")
+	}
+	defer polDefs.Close()
+	pols := make(map[tableKey][]*Policy)
+	for polDefs.Next() {
+		var key tableKey
+		var p Policy
+		err := polDefs.Scan(
+			&key.Schema,
+			&key.Name,
+			&p.Name,
+			&p.Command,
+			&p.Permissive,
+			pq.Array(&p.Roles),
+			&p.Using,
+			&p.WithCheck,
+		)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to scan")
+		}
+		pols[key] = append(pols[key], &p)
+	}
+	if err := polDefs.Err(); err != nil {
+		return nil, errors.Wrap(err, "failed to load policy def")
+	}
+	return pols, nil
+}
+
+// LoadGrantDef load Postgres table and column privileges
+func LoadGrantDef(ctx context.Context, db QueryerContext, schema string, tbl *Table) ([]*Grant, error) {
+	grants, err := loadGrantDefs(ctx, db, []string{schema}, []string{tbl.Name})
+	if err != nil {
+		return nil, err
+	}
+	return grants[keyOf(tbl)], nil
+}
+
+// loadGrantDefs load privileges of all tables in schemas, or only of the given tables if any
+func loadGrantDefs(ctx context.Context, db QueryerContext, schemas, tables []string) (map[tableKey][]*Grant, error) {
+	grantDefs, err := db.QueryContext(ctx, grantDefSQL, pq.Array(schemas), pq.Array(tables))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to load grant def")
+	}
+	defer grantDefs.Close()
+	grants := make(map[tableKey][]*Grant)
+	for grantDefs.Next() {
+		var key tableKey
+		var g Grant
+		err := grantDefs.Scan(
+			&key.Schema,
+			&key.Name,
+			&g.Column,
+			&g.Grantee,
+			&g.Privileges,
+		)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to scan")
+		}
+		grants[key] = append(grants[key], &g)
+	}
+	if err := grantDefs.Err(); err != nil {
+		return nil, errors.Wrap(err, "failed to load grant def")
+	}
+	return grants, nil
+}
+
+// CheckSelectRoles check that roles exist and that their combinations can be told apart by color
+func CheckSelectRoles(ctx context.Context, db QueryerContext, roles []string) error {
+	if len(roles) > maxSelectRoles {
+		return errors.Errorf("at most %d select roles can be colored, got %d", maxSelectRoles, len(roles))
+	}
+	rows, err := db.QueryContext(ctx, unknownRoleSQL, pq.Array(roles))
+	if err != nil {
+		return errors.Wrap(err, "failed to check select roles")
+	}
+	defer rows.Close()
+	var unknown []string
+	for rows.Next() {
+		var r string
+		if err := rows.Scan(&r); err != nil {
+			return errors.Wrap(err, "failed to scan")
+		}
+		unknown = append(unknown, r)
+	}
+	if err := rows.Err(); err != nil {
+		return errors.Wrap(err, "failed to check select roles")
+	}
+	if len(unknown) != 0 {
+		return errors.Errorf("unknown select roles: %s", strings.Join(unknown, ", "))
+	}
+	return nil
+}
+
+// LoadSelectRoles load which of the given roles can SELECT each table
+func LoadSelectRoles(ctx context.Context, db QueryerContext, schema string, tbls []*Table, roles []string) error {
+	roleDefs, err := db.QueryContext(ctx, selectRoleDefSQL, schema, pq.Array(roles))
+	if err != nil {
+		return errors.Wrap(err, "failed to load select roles")
+	}
+	defer roleDefs.Close()
+	for roleDefs.Next() {
+		var name string
+		var selectRoles []string
+		if err := roleDefs.Scan(&name, pq.Array(&selectRoles)); err != nil {
+			return errors.Wrap(err, "failed to scan")
+		}
+		for _, tbl := range tbls {
+			if tbl.Schema == schema && tbl.Name == name {
+				tbl.SelectRoles = selectRoles
+			}
+		}
+	}
+	if err := roleDefs.Err(); err != nil {
+		return errors.Wrap(err, "failed to load select roles")
+	}
+	return nil
+}
+
+// LoadTableStats load Postgres size and maintenance statistics of tables
+func LoadTableStats(ctx context.Context, db QueryerContext, schema string, tbls []*Table) error {
+	statDefs, err := db.QueryContext(ctx, tableStatsSQL, schema)
+	if err != nil {
+		return errors.Wrap(err, "failed to load table stats")
+	}
+	defer statDefs.Close()
+	for statDefs.Next() {
+		var name string
+		var st TableStats
+		err := statDefs.Scan(
+			&name,
+			&st.RowEstimate,
+			&st.TotalBytes,
+			&st.LastVacuum,
+			&st.LastAnalyze,
+		)
+		if err != nil {
+			return errors.Wrap(err, "failed to scan")
+		}
+		for _, tbl := range tbls {
+			if tbl.Schema == schema && tbl.Name == name {
+				stats := st
+				tbl.Stats = &stats
+			}
+		}
+	}
+	if err := statDefs.Err(); err != nil {
+		return errors.Wrap(err, "failed to load table stats")
+	}
+	return nil
+}
+
+// LoadColumnStats load Postgres column statistics, most common values only if mcv is set
+func LoadColumnStats(ctx context.Context, db QueryerContext, schema string, tbls []*Table, mcv bool) error {
+	statDefs, err := db.QueryContext(ctx, columnStatsSQL, schema, mcv)
+	if err != nil {
+		return errors.Wrap(err, "failed to load column stats")
+	}
+	defer statDefs.Close()
+	for statDefs.Next() {
+		var tblName, colName string
+		var st ColumnStats
+		err := statDefs.Scan(
+			&tblName,
+			&colName,
+			&st.NullFrac,
+			&st.NDistinct,
+			pq.Array(&st.MostCommonVals),
+		)
+		if err != nil {
+			return errors.Wrap(err, "failed to scan")
+		}
+		for _, tbl := range tbls {
+			if tbl.Schema != schema || tbl.Name != tblName {
+				continue
+			}
+			for _, col := range tbl.Columns {
+				if col.Name == colName {
+					stats := st
+					col.Stats = &stats
+				}
+			}
+		}
+	}
+	if err := statDefs.Err(); err != nil {
+		return errors.Wrap(err, "failed to load column stats")
+	}
+	return nil
+}
+
+// LoadInheritanceDef load Postgres parents of a table created with INHERITS
+func LoadInheritanceDef(ctx context.Context, db QueryerContext, schema string, tbl *Table) ([]*Inheritance, error) {
+	inhs, err := loadInheritanceDefs(ctx, db, []string{schema}, []string{tbl.Name})
+	if err != nil {
+		return nil, err
+	}
+	return inhs[keyOf(tbl)], nil
+}
+
+// loadInheritanceDefs load parents of all INHERITS tables in schemas, or only of the given tables if any
+func loadInheritanceDefs(ctx context.Context, db QueryerContext, schemas, tables []string) (map[tableKey][]*Inheritance, error) {
+	inhDefs, err := db.QueryContext(ctx, inheritanceDefSQL, pq.Array(schemas), pq.Array(tables))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to load inheritance def")
+	}
+	defer inhDefs.Close()
+	inhs := make(map[tableKey][]*Inheritance)
+	for inhDefs.Next() {
+		var key tableKey
+		var i Inheritance
+		err := inhDefs.Scan(
+			&key.Schema,
+			&key.Name,
+			&i.ParentSchemaName,
+			&i.ParentTableName,
+		)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to scan")
+		}
+		inhs[key] = append(inhs[key], &i)
+	}
+	if err := inhDefs.Err(); err != nil {
+		return nil, errors.Wrap(err, "failed to load inheritance def")
+	}
+	return inhs, nil
+}
+
+// ResolveInheritance link inheritances to parent tables found in tbls
+func ResolveInheritance(tbls []*Table) {
+	for _, tbl := range tbls {
+		for _, i := range tbl.Inherits {
+			for _, p := range tbls {
+				if p.Schema == i.ParentSchemaName && p.Name == i.ParentTableName {
+					i.ParentTable = p
+				}
+			}
+		}
 	}
-	return tbls, nil
 }
 
-// LoadTableDef load Postgres table definition
-func LoadTableDef(db Queryer, schema string, skipFlags string) ([]*Table, error) {
-    fmt.Fprintln(os.Stdout, "Load schema: " + schema)
-	tbDefs, err := db.Query(tableDefSQL, schema)
-	var tbls []*Table
+// loadTables load tables of schemas, leaving out views if skipFlags has "v"
+func loadTables(ctx context.Context, db QueryerContext, schemas []string, skipFlags string) ([]*Table, error) {
+	tbDefs, err := db.QueryContext(ctx, tableDefSQL, pq.Array(schemas))
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to load table def")
 	}
+	defer tbDefs.Close()
+	var tbls []*Table
 	for tbDefs.Next() {
-		t := &Table{Schema: schema}
+		t := &Table{}
 		err := tbDefs.Scan(
+			&t.Schema,
 			&t.Name,
+			&t.Kind,
+			&t.IsPartition,
+			&t.PartitionKey,
+			&t.RowSecurity,
+			&t.ForceRowSecurity,
 			&t.Comment,
 		)
 		if err != nil {
 			return nil, errors.Wrap(err, "failed to scan")
 		}
-		fmt.Fprintln(os.Stdout, "Load table: " + schema + "." + t.Name)
-		cols, err := LoadColumnDef(db, schema, t.Name)
-		if err != nil {
-			return nil, errors.Wrap(err, fmt.Sprintf("failed to get columns of %s", t.Name))
+		if (t.IsView() || t.IsMaterializedView()) && strings.Contains(skipFlags, "v") {
+			continue
 		}
-		t.Columns = cols
 		tbls = append(tbls, t)
 	}
+	if err := tbDefs.Err(); err != nil {
+		return nil, errors.Wrap(err, "failed to load table def")
+	}
+	return tbls, nil
+}
+
+// catalog rows of all requested schemas keyed by table
+type catalog struct {
+	tbls   []*Table
+	cols   map[tableKey][]*Column
+	parts  map[tableKey][]*Partition
+	inhs   map[tableKey][]*Inheritance
+	idxs   map[tableKey][]*Index
+	cons   map[tableKey][]*Constraint
+	uniqs  map[tableKey][]*UniqueGroup
+	tgs    map[tableKey][]*Trigger
+	pols   map[tableKey][]*Policy
+	grants map[tableKey][]*Grant
+	fks    map[tableKey][]*ForeignKey
+}
+
+// fetchJob one catalog query run by a worker on its own connection
+type fetchJob func(ctx context.Context, db QueryerContext) error
+
+// catalogJobs one set-based query per catalog covering all schemas
+func catalogJobs(schemas []string, skipFlags string, c *catalog) []fetchJob {
+	jobs := []fetchJob{
+		func(ctx context.Context, db QueryerContext) (err error) {
+			c.tbls, err = loadTables(ctx, db, schemas, skipFlags)
+			return errors.Wrap(err, "failed to get tables")
+		},
+		func(ctx context.Context, db QueryerContext) (err error) {
+			c.cols, err = loadColumnDefs(ctx, db, schemas, nil)
+			return errors.Wrap(err, "failed to get columns")
+		},
+		func(ctx context.Context, db QueryerContext) (err error) {
+			c.parts, err = loadPartitionDefs(ctx, db, schemas, nil)
+			return errors.Wrap(err, "failed to get partitions")
+		},
+		func(ctx context.Context, db QueryerContext) (err error) {
+			c.inhs, err = loadInheritanceDefs(ctx, db, schemas, nil)
+			return errors.Wrap(err, "failed to get parents")
+		},
+		func(ctx context.Context, db QueryerContext) (err error) {
+			c.uniqs, err = loadUniqueDefs(ctx, db, schemas, nil)
+			return errors.Wrap(err, "failed to get unique groups")
+		},
+	}
+	if !strings.Contains(skipFlags, "i") {
+		jobs = append(jobs, func(ctx context.Context, db QueryerContext) (err error) {
+			c.idxs, err = loadIndexDefs(ctx, db, schemas, nil)
+			return errors.Wrap(err, "failed to get indexes")
+		})
+	}
+	if !strings.Contains(skipFlags, "c") {
+		jobs = append(jobs, func(ctx context.Context, db QueryerContext) (err error) {
+			c.cons, err = loadConstraintDefs(ctx, db, schemas, nil)
+			return errors.Wrap(err, "failed to get constraints")
+		})
+	}
+	if !strings.Contains(skipFlags, "t") {
+		jobs = append(jobs, func(ctx context.Context, db QueryerContext) (err error) {
+			c.tgs, err = loadTriggerDefs(ctx, db, schemas, nil)
+			return errors.Wrap(err, "failed to get triggers")
+		})
+	}
+	if !strings.Contains(skipFlags, "a") {
+		jobs = append(jobs, func(ctx context.Context, db QueryerContext) (err error) {
+			c.pols, err = loadPolicyDefs(ctx, db, schemas, nil)
+			return errors.Wrap(err, "failed to get policies")
+		}, func(ctx context.Context, db QueryerContext) (err error) {
+			c.grants, err = loadGrantDefs(ctx, db, schemas, nil)
+			return errors.Wrap(err, "failed to get grants")
+		})
+	}
 	if !strings.Contains(skipFlags, "f") {
-	    for _, tbl := range tbls {
-    		fks, err := LoadForeignKeyDef(db, schema, tbls, tbl)
-    		if err != nil {
-    			return nil, errors.Wrap(err, fmt.Sprintf("failed to get fks of %s", tbl.Name))
-    		}
-    		tbl.ForeingKeys = fks
-    	}
+		jobs = append(jobs, func(ctx context.Context, db QueryerContext) (err error) {
+			c.fks, err = loadForeignKeyDefs(ctx, db, schemas, nil)
+			return errors.Wrap(err, "failed to get fks")
+		})
+	}
+	return jobs
+}
+
+// runJobs run jobs on a pool of one worker per db, stopping at the first error
+func runJobs(ctx context.Context, dbs []QueryerContext, jobs []fetchJob) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	jobc := make(chan fetchJob)
+	errc := make(chan error, len(dbs))
+	var wg sync.WaitGroup
+	for _, db := range dbs {
+		wg.Add(1)
+		go func(db QueryerContext) {
+			defer wg.Done()
+			for job := range jobc {
+				if err := job(ctx, db); err != nil {
+					errc <- err
+					cancel()
+					return
+				}
+			}
+		}(db)
+	}
+feed:
+	for _, job := range jobs {
+		select {
+		case jobc <- job:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(jobc)
+	wg.Wait()
+	close(errc)
+	if err := <-errc; err != nil {
+		return err
+	}
+	return ctx.Err()
+}
+
+// LoadTableDefForSchemas load Postgres table definition of all schemas
+func LoadTableDefForSchemas(ctx context.Context, db QueryerContext, schemas []string, skipFlags string) ([]*Table, error) {
+	return LoadTableDefParallel(ctx, []QueryerContext{db}, schemas, skipFlags)
+}
+
+// LoadTableDefParallel load Postgres table definition of all schemas, spreading the
+// catalog queries over one worker per db. Each catalog is read with one query for all
+// schemas. dbs should share one snapshot, see BeginSnapshot.
+// The result does not depend on the number of workers.
+func LoadTableDefParallel(ctx context.Context, dbs []QueryerContext, schemas []string, skipFlags string) ([]*Table, error) {
+	var uniq []string
+	seen := make(map[string]bool)
+	for _, schema := range schemas {
+		if !seen[schema] {
+			seen[schema] = true
+			uniq = append(uniq, schema)
+		}
 	}
+	version, err := serverVersion(ctx, dbs[0])
+	if err != nil {
+		return nil, err
+	}
+	if err := checkServerVersion(version); err != nil {
+		return nil, err
+	}
+	for _, schema := range uniq {
+		fmt.Fprintln(os.Stderr, "Load schema: " + schema)
+	}
+	var c catalog
+	if err := runJobs(ctx, dbs, catalogJobs(uniq, skipFlags, &c)); err != nil {
+		return nil, err
+	}
+
+	// keep tables in the order the schemas were requested
+	bySchema := make(map[string][]*Table)
+	for _, tbl := range c.tbls {
+		bySchema[tbl.Schema] = append(bySchema[tbl.Schema], tbl)
+	}
+	var tbls []*Table
+	for _, schema := range uniq {
+		tbls = append(tbls, bySchema[schema]...)
+	}
+	for _, tbl := range tbls {
+		key := keyOf(tbl)
+		tbl.Columns = c.cols[key]
+		for _, col := range tbl.Columns {
+			if col.IsPrimaryKey && (col.IsIdentity() || col.IsSerial()) {
+				tbl.AutoGenPk = true
+			}
+		}
+		if tbl.IsPartitioned() {
+			tbl.Partitions = c.parts[key]
+			resolvePartitions(tbls, tbl.Partitions)
+		}
+		if tbl.Kind == KindTable && !tbl.IsPartition {
+			tbl.Inherits = c.inhs[key]
+		}
+		if !tbl.IsView() {
+			tbl.Indexes = c.idxs[key]
+			tbl.UniqueGroups = c.uniqs[key]
+		}
+		if !tbl.IsView() && !tbl.IsMaterializedView() {
+			tbl.Constraints = c.cons[key]
+		}
+		if !tbl.IsMaterializedView() {
+			tbl.Triggers = c.tgs[key]
+		}
+		tbl.Policies = c.pols[key]
+		tbl.Grants = c.grants[key]
+		tbl.ForeingKeys = c.fks[key]
+		attachForeignKeys(tbl, tbl.ForeingKeys)
+	}
+	ResolveInheritance(tbls)
+	var fks []*ForeignKey
+	for _, tbl := range tbls {
+		fks = append(fks, tbl.ForeingKeys...)
+	}
+	ResolveForeignKeys(tbls, fks)
 	return tbls, nil
 }
 
+// LoadTableDef load Postgres table definition
+func LoadTableDef(ctx context.Context, db QueryerContext, schema string, skipFlags string) ([]*Table, error) {
+	return LoadTableDefForSchemas(ctx, db, []string{schema}, skipFlags)
+}
+
 // TableToUMLEntry table entry
 func TableToUMLEntry(tbls []*Table) ([]byte, error) {
 	tpl, err := template.New("entry").Parse(entryTmpl)
@@ -259,7 +1785,7 @@ func TableToUMLTable(tbl *Table) ([]byte, error) {
 
 // TableToRSTTable table entry
 func TableToRSTTable(tbl *Table) ([]byte, error) {
-	tpl, err := template.New("rsttable").Parse(rstTableTmpl)
+	tpl, err := template.New("rsttable").Funcs(rstFuncs).Parse(rstTableTmpl)
 	if err != nil {
 		return nil, err
 	}
@@ -291,7 +1817,7 @@ func ForeignKeyToUMLRelation(tbls []*Table) ([]byte, error) {
 
 // ForeignKeyToUMLRelation2 relation
 func ForeignKeyToUMLRelation2(tbl *Table) ([]byte, []byte, error) {
-    fmt.Fprintln(os.Stdout, "ForeignKeyToUMLRelation2: " + tbl.Name)
+    fmt.Fprintln(os.Stderr, "ForeignKeyToUMLRelation2: " + tbl.Name)
 	tpl, err := template.New("relation").Parse(relationTmpl)
 	if err != nil {
 		return nil, nil, err
@@ -303,7 +1829,7 @@ func ForeignKeyToUMLRelation2(tbl *Table) ([]byte, []byte, error) {
         if err := tpl.Execute(buf, fk); err != nil {
             return nil, nil, errors.Wrapf(err, "failed to execute template: %s", fk.ConstraintName)
         }
-        if fk.ConstraintSchemaName != fk.SourceSchemaName {
+        if fk.SourceSchemaName != fk.TargetSchemaName {
             global_src2 = append(global_src2, buf.Bytes()...)
         } else {
             schema_src1 = append(schema_src1, buf.Bytes()...)
@@ -312,6 +1838,367 @@ func ForeignKeyToUMLRelation2(tbl *Table) ([]byte, []byte, error) {
 	return schema_src1, global_src2, nil
 }
 
+// TableEnums enum types used by columns of tbls
+func TableEnums(tbls []*Table) []*Enum {
+	var enums []*Enum
+	seen := make(map[string]bool)
+	for _, tbl := range tbls {
+		for _, c := range tbl.Columns {
+			if c.Enum == nil {
+				continue
+			}
+			key := c.Enum.Schema + "." + c.Enum.Name
+			if !seen[key] {
+				seen[key] = true
+				enums = append(enums, c.Enum)
+			}
+		}
+	}
+	return enums
+}
+
+// MergeEnums enums of a followed by those of b that are not in a
+func MergeEnums(a, b []*Enum) []*Enum {
+	seen := make(map[string]bool)
+	var enums []*Enum
+	for _, e := range append(append([]*Enum(nil), a...), b...) {
+		key := e.Schema + "." + e.Name
+		if !seen[key] {
+			seen[key] = true
+			enums = append(enums, e)
+		}
+	}
+	return enums
+}
+
+// EnumToUMLEntry enum entry
+func EnumToUMLEntry(enums []*Enum) ([]byte, error) {
+	tpl, err := template.New("enum").Parse(enumTmpl)
+	if err != nil {
+		return nil, err
+	}
+	var src []byte
+	for _, e := range enums {
+		buf := new(bytes.Buffer)
+		if err := tpl.Execute(buf, e); err != nil {
+			return nil, errors.Wrapf(err, "failed to execute template: %s", e.Name)
+		}
+		src = append(src, buf.Bytes()...)
+	}
+	return src, nil
+}
+
+// TableDomains domain types used by columns of tbls
+func TableDomains(tbls []*Table) []*Domain {
+	var domains []*Domain
+	seen := make(map[string]bool)
+	for _, tbl := range tbls {
+		for _, c := range tbl.Columns {
+			if c.Domain == nil {
+				continue
+			}
+			key := c.Domain.Schema + "." + c.Domain.Name
+			if !seen[key] {
+				seen[key] = true
+				domains = append(domains, c.Domain)
+			}
+		}
+	}
+	return domains
+}
+
+// TableCompositeTypes composite types used by columns of tbls
+func TableCompositeTypes(tbls []*Table) []*CompositeType {
+	var types []*CompositeType
+	seen := make(map[string]bool)
+	for _, tbl := range tbls {
+		for _, c := range tbl.Columns {
+			if c.Composite == nil {
+				continue
+			}
+			key := c.Composite.Schema + "." + c.Composite.Name
+			if !seen[key] {
+				seen[key] = true
+				types = append(types, c.Composite)
+			}
+		}
+	}
+	return types
+}
+
+// TypeToUMLEntry domain and composite type entry
+func TypeToUMLEntry(domains []*Domain, types []*CompositeType) ([]byte, error) {
+	tpl, err := template.New("type").Parse(typeTmpl)
+	if err != nil {
+		return nil, err
+	}
+	var src []byte
+	for _, d := range domains {
+		buf := new(bytes.Buffer)
+		if err := tpl.ExecuteTemplate(buf, "domain", d); err != nil {
+			return nil, errors.Wrapf(err, "failed to execute template: %s", d.Name)
+		}
+		src = append(src, buf.Bytes()...)
+	}
+	for _, t := range types {
+		buf := new(bytes.Buffer)
+		if err := tpl.ExecuteTemplate(buf, "composite", t); err != nil {
+			return nil, errors.Wrapf(err, "failed to execute template: %s", t.Name)
+		}
+		src = append(src, buf.Bytes()...)
+	}
+	return src, nil
+}
+
+// TypeToRST domain and composite type description
+func TypeToRST(schema string, domains []*Domain, types []*CompositeType) ([]byte, error) {
+	if len(domains) == 0 && len(types) == 0 {
+		return nil, nil
+	}
+	tpl, err := template.New("rsttype").Funcs(rstFuncs).Parse(rstTypeTmpl)
+	if err != nil {
+		return nil, err
+	}
+	buf := new(bytes.Buffer)
+	if err := tpl.Execute(buf, struct {
+		Schema     string
+		Domains    []*Domain
+		Composites []*CompositeType
+	}{schema, domains, types}); err != nil {
+		return nil, errors.Wrap(err, "failed to execute template: types")
+	}
+	return buf.Bytes(), nil
+}
+
+// TypeToUMLRelation relation between tables and enum, domain or composite types used by their columns
+func TypeToUMLRelation(tbls []*Table) ([]byte, error) {
+	tpl, err := template.New("typerelation").Parse(typeRelationTmpl)
+	if err != nil {
+		return nil, err
+	}
+	var src []byte
+	for _, tbl := range tbls {
+		for _, c := range tbl.Columns {
+			if c.UserType() == "" {
+				continue
+			}
+			buf := new(bytes.Buffer)
+			if err := tpl.Execute(buf, struct {
+				Table  *Table
+				Column *Column
+			}{tbl, c}); err != nil {
+				return nil, errors.Wrapf(err, "failed to execute template: %s", c.UserType())
+			}
+			src = append(src, buf.Bytes()...)
+		}
+	}
+	return src, nil
+}
+
+// TriggerFunctionToUML trigger function components and their relations to tables
+func TriggerFunctionToUML(tbls []*Table) ([]byte, []byte, error) {
+	tpl, err := template.New("triggerfunction").Parse(triggerFunctionTmpl)
+	if err != nil {
+		return nil, nil, err
+	}
+	var entrySrc []byte
+	var relSrc []byte
+	seen := make(map[string]bool)
+	for _, tbl := range tbls {
+		for _, tg := range tbl.Triggers {
+			if !seen[tg.FunctionAlias()] {
+				seen[tg.FunctionAlias()] = true
+				buf := new(bytes.Buffer)
+				if err := tpl.ExecuteTemplate(buf, "component", tg); err != nil {
+					return nil, nil, errors.Wrapf(err, "failed to execute template: %s", tg.Function())
+				}
+				entrySrc = append(entrySrc, buf.Bytes()...)
+			}
+			buf := new(bytes.Buffer)
+			if err := tpl.ExecuteTemplate(buf, "relation", struct {
+				Table *Table
+				*Trigger
+			}{tbl, tg}); err != nil {
+				return nil, nil, errors.Wrapf(err, "failed to execute template: %s", tg.Name)
+			}
+			relSrc = append(relSrc, buf.Bytes()...)
+		}
+	}
+	return entrySrc, relSrc, nil
+}
+
+// FunctionToRST function description
+func FunctionToRST(schema string, fns []*Function) ([]byte, error) {
+	if len(fns) == 0 {
+		return nil, nil
+	}
+	tpl, err := template.New("rstfunction").Funcs(rstFuncs).Parse(rstFunctionTmpl)
+	if err != nil {
+		return nil, err
+	}
+	buf := new(bytes.Buffer)
+	if err := tpl.Execute(buf, struct {
+		Schema    string
+		Functions []*Function
+	}{schema, fns}); err != nil {
+		return nil, errors.Wrap(err, "failed to execute template: functions")
+	}
+	return buf.Bytes(), nil
+}
+
+var selectRolePalette = []string{
+	"#AAFFAA", "#AAAAFF", "#FFFFAA", "#FFAAFF", "#AAFFFF", "#FFDDAA", "#DDAAFF", "#DDDDDD",
+}
+
+// maxSelectRoles number of roles whose non-empty combinations all have a palette color
+const maxSelectRoles = 3
+
+func selectRoleMask(roles, selectRoles []string) int {
+	mask := 0
+	for i, r := range roles {
+		for _, s := range selectRoles {
+			if r == s {
+				mask |= 1 << uint(i)
+			}
+		}
+	}
+	return mask
+}
+
+func selectRoleColor(mask int) string {
+	if mask == 0 {
+		return "#FFFFFF"
+	}
+	return selectRolePalette[mask-1]
+}
+
+// ColorBySelectRoles color tables by which of the given roles can SELECT them
+func ColorBySelectRoles(tbls []*Table, roles []string) []*Table {
+	for _, tbl := range tbls {
+		tbl.Color = selectRoleColor(selectRoleMask(roles, tbl.SelectRoles))
+	}
+	return tbls
+}
+
+// SelectRoleLegend legend lines for role combinations found in tbls
+func SelectRoleLegend(tbls []*Table, roles []string) []byte {
+	seen := make(map[int]string)
+	var masks []int
+	for _, tbl := range tbls {
+		mask := selectRoleMask(roles, tbl.SelectRoles)
+		if _, ok := seen[mask]; !ok {
+			seen[mask] = strings.Join(tbl.SelectRoles, ", ")
+			masks = append(masks, mask)
+		}
+	}
+	sort.Ints(masks)
+	src := []byte("    SELECT granted to\n")
+	for _, mask := range masks {
+		names := seen[mask]
+		if names == "" {
+			names = "none of " + strings.Join(roles, ", ")
+		}
+		src = append(src, []byte("    <back:"+selectRoleColor(mask)+">    </back> "+names+"\n")...)
+	}
+	return src
+}
+
+var sizeClassColors = map[string]string{
+	"small":  "#DDFFDD",
+	"medium": "#FFF2AA",
+	"huge":   "#FFAAAA",
+}
+
+// ColorBySize color tables by size class of their statistics
+func ColorBySize(tbls []*Table) []*Table {
+	for _, tbl := range tbls {
+		if tbl.Stats != nil {
+			tbl.Color = sizeClassColors[tbl.Stats.SizeClass()]
+		}
+	}
+	return tbls
+}
+
+// SizeLegend legend lines for size classes
+func SizeLegend() []byte {
+	src := []byte("    table size\n")
+	src = append(src, []byte("    <back:"+sizeClassColors["small"]+">    </back> small < 100 MB\n")...)
+	src = append(src, []byte("    <back:"+sizeClassColors["medium"]+">    </back> medium < 10 GB\n")...)
+	src = append(src, []byte("    <back:"+sizeClassColors["huge"]+">    </back> huge\n")...)
+	return src
+}
+
+// PartitionToUMLRelation partition relation
+func PartitionToUMLRelation(tbls []*Table) ([]byte, error) {
+	tpl, err := template.New("partition").Parse(partitionTmpl)
+	if err != nil {
+		return nil, err
+	}
+	var src []byte
+	for _, tbl := range tbls {
+		for _, p := range tbl.Partitions {
+			if _, found := FindTable(tbls, p.Schema, p.Name); !found {
+				continue
+			}
+			buf := new(bytes.Buffer)
+			if err := tpl.Execute(buf, struct {
+				Parent *Table
+				*Partition
+			}{tbl, p}); err != nil {
+				return nil, errors.Wrapf(err, "failed to execute template: %s", p.Name)
+			}
+			src = append(src, buf.Bytes()...)
+		}
+	}
+	return src, nil
+}
+
+// InheritanceToUMLRelation inheritance relation
+func InheritanceToUMLRelation(tbls []*Table) ([]byte, error) {
+	var src []byte
+	for _, tbl := range tbls {
+		schemaSrc, globalSrc, err := InheritanceToUMLRelation2(tbl)
+		if err != nil {
+			return nil, err
+		}
+		src = append(src, schemaSrc...)
+		src = append(src, globalSrc...)
+	}
+	return src, nil
+}
+
+// InheritanceToUMLRelation2 inheritance relation split by same schema and cross schema
+func InheritanceToUMLRelation2(tbl *Table) ([]byte, []byte, error) {
+	tpl, err := template.New("inheritance").Parse(inheritanceTmpl)
+	if err != nil {
+		return nil, nil, err
+	}
+	var schemaSrc []byte
+	var globalSrc []byte
+	for _, i := range tbl.Inherits {
+		buf := new(bytes.Buffer)
+		if err := tpl.Execute(buf, struct {
+			Child *Table
+			*Inheritance
+		}{tbl, i}); err != nil {
+			return nil, nil, errors.Wrapf(err, "failed to execute template: %s", tbl.Name)
+		}
+		if i.ParentSchemaName != tbl.Schema {
+			globalSrc = append(globalSrc, buf.Bytes()...)
+		} else {
+			schemaSrc = append(schemaSrc, buf.Bytes()...)
+		}
+	}
+	return schemaSrc, globalSrc, nil
+}
+
+func umlAlias(schema, name string) string {
+	if schema == "" {
+		return name
+	}
+	return schema + "." + name
+}
+
 func contains(v string, l []string) bool {
 	i := sort.SearchStrings(l, v)
 	if i < len(l) && l[i] == v {
@@ -320,16 +2207,72 @@ func contains(v string, l []string) bool {
 	return false
 }
 
+// hasString like contains, for lists that are not sorted
+func hasString(l []string, v string) bool {
+	for _, s := range l {
+		if s == v {
+			return true
+		}
+	}
+	return false
+}
+
+func containsTable(schema, name string, l []string) bool {
+	return contains(name, l) || contains(schema+"."+name, l)
+}
+
+// schemaTables tables of schemas in schema order, leaving out what skipFlags names
+// like LoadTableDef does. Used by loaders that read a whole model at once.
+func schemaTables(all []*Table, schemas []string, skipFlags string) []*Table {
+	var tbls []*Table
+	seen := make(map[string]bool)
+	for _, schema := range schemas {
+		if seen[schema] {
+			continue
+		}
+		seen[schema] = true
+		for _, tbl := range all {
+			if tbl.Schema != schema {
+				continue
+			}
+			if (tbl.IsView() || tbl.IsMaterializedView()) && strings.Contains(skipFlags, "v") {
+				continue
+			}
+			if strings.Contains(skipFlags, "i") {
+				tbl.Indexes = nil
+			}
+			if strings.Contains(skipFlags, "c") {
+				tbl.Constraints = nil
+			}
+			if strings.Contains(skipFlags, "t") {
+				tbl.Triggers = nil
+			}
+			if strings.Contains(skipFlags, "a") {
+				tbl.Policies = nil
+				tbl.Grants = nil
+			}
+			if strings.Contains(skipFlags, "f") {
+				tbl.ForeingKeys = nil
+				for _, c := range tbl.Columns {
+					c.IsForeignKey = false
+				}
+			}
+			tbls = append(tbls, tbl)
+		}
+	}
+	return tbls
+}
+
 // FilterTables filter tables
 func FilterTables(match bool, tbls []*Table, tblNames []string) []*Table {
 	sort.Strings(tblNames)
 
 	var target []*Table
 	for _, tbl := range tbls {
-		if contains(tbl.Name, tblNames) == match {
+		if containsTable(tbl.Schema, tbl.Name, tblNames) == match {
 			var fks []*ForeignKey
 			for _, fk := range tbl.ForeingKeys {
-				if contains(fk.TargetTableName, tblNames) == match {
+				if containsTable(fk.TargetSchemaName, fk.TargetTableName, tblNames) == match {
 					fks = append(fks, fk)
 				}
 			}
@@ -340,6 +2283,34 @@ func FilterTables(match bool, tbls []*Table, tblNames []string) []*Table {
 	return target
 }
 
+// FilterPartitions filter out partitions so that only partitioned parents remain
+func FilterPartitions(tbls []*Table) []*Table {
+	var target []*Table
+	for _, tbl := range tbls {
+		if !tbl.IsPartition {
+			target = append(target, tbl)
+		}
+	}
+	return target
+}
+
+// HideInheritedColumns remove columns inherited from parents of INHERITS tables
+func HideInheritedColumns(tbls []*Table) []*Table {
+	for _, tbl := range tbls {
+		if len(tbl.Inherits) == 0 {
+			continue
+		}
+		var cols []*Column
+		for _, c := range tbl.Columns {
+			if !c.IsInherited {
+				cols = append(cols, c)
+			}
+		}
+		tbl.Columns = cols
+	}
+	return tbls
+}
+
 // FilterTableSuffix filter tables by suffix
 func FilterTableSuffix(tbls []*Table, xTblNameSuffix string) []*Table {
 	var target []*Table
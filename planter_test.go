@@ -1,10 +1,17 @@
 package main
 
 import (
+	"bytes"
+	"context"
 	"database/sql"
+	"errors"
 	"io/ioutil"
+	"os"
+	"path/filepath"
 	"reflect"
+	"strings"
 	"testing"
+	"time"
 )
 
 // before running test, create user and database
@@ -36,7 +43,7 @@ func TestLoadColumnDef(t *testing.T) {
 
 	schema := "public"
 	table := "customer"
-	cols, err := LoadColumnDef(conn, schema, table)
+	cols, err := LoadColumnDef(context.Background(), conn, schema, table)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -45,57 +52,74 @@ func TestLoadColumnDef(t *testing.T) {
 			FieldOrdinal: 1,
 			Name:         "id",
 			Comment:      sql.NullString{},
-			DataType:     "bigint",
+			DataType:     "BIGINT",
 			DDLType:      "bigserial",
 			NotNull:      true,
 			IsPrimaryKey: true,
+			DefVal:       sql.NullString{String: "nextval('customer_id_seq'::regclass)", Valid: true},
+			SequenceName: sql.NullString{String: "public.customer_id_seq", Valid: true},
+			TypeSchema:   "pg_catalog",
+			TypeName:     "int8",
 		},
 		&Column{
 			FieldOrdinal: 2,
 			Name:         "name",
 			Comment:      sql.NullString{String: "Customer Name", Valid: true},
-			DataType:     "text",
+			DataType:     "TEXT",
 			DDLType:      "text",
 			NotNull:      true,
 			IsPrimaryKey: false,
+			TypeSchema:   "pg_catalog",
+			TypeName:     "text",
 		},
 		&Column{
 			FieldOrdinal: 3,
 			Name:         "zip_code",
 			Comment:      sql.NullString{String: "Customer Zip Code", Valid: true},
-			DataType:     "text",
+			DataType:     "TEXT",
 			DDLType:      "text",
 			NotNull:      true,
 			IsPrimaryKey: false,
+			TypeSchema:   "pg_catalog",
+			TypeName:     "text",
 		},
 		&Column{
 			FieldOrdinal: 4,
 			Name:         "address",
 			Comment:      sql.NullString{String: "Customer Address", Valid: true},
-			DataType:     "text",
+			DataType:     "TEXT",
 			DDLType:      "text",
 			NotNull:      true,
 			IsPrimaryKey: false,
+			TypeSchema:   "pg_catalog",
+			TypeName:     "text",
 		},
 		&Column{
 			FieldOrdinal: 5,
 			Name:         "phone_number",
 			Comment:      sql.NullString{String: "Customer Phone Number", Valid: true},
-			DataType:     "text",
+			DataType:     "TEXT",
 			DDLType:      "text",
 			NotNull:      true,
 			IsPrimaryKey: false,
+			TypeSchema:   "pg_catalog",
+			TypeName:     "text",
 		},
 		&Column{
 			FieldOrdinal: 6,
 			Name:         "registered_at",
 			Comment:      sql.NullString{},
-			DataType:     "timestamp with time zone",
+			DataType:     "TIMESTAMPTZ",
 			DDLType:      "timestamp with time zone",
 			NotNull:      true,
 			IsPrimaryKey: false,
+			TypeSchema:   "pg_catalog",
+			TypeName:     "timestamptz",
 		},
 	}
+	if len(cols) != len(expected) {
+		t.Fatalf("want %d got %d", len(expected), len(cols))
+	}
 	for i := range cols {
 		if !reflect.DeepEqual(cols[i], expected[i]) {
 			t.Errorf("\n%+v\n%+v", cols[i], expected[i])
@@ -118,12 +142,79 @@ func TestFindTableByName(t *testing.T) {
 	}
 }
 
+func TestFindTableSchemaQualified(t *testing.T) {
+	tbls := []*Table{
+		&Table{Schema: "public", Name: "orders"},
+		&Table{Schema: "sales", Name: "orders"},
+	}
+	tbl, found := FindTable(tbls, "sales", "orders")
+	if !found {
+		t.Fatal("sales.orders not found")
+	}
+	if tbl.Schema != "sales" {
+		t.Errorf("want sales got %s", tbl.Schema)
+	}
+	tbl, found = FindTableByName(tbls, "sales.orders")
+	if !found {
+		t.Fatal("sales.orders not found")
+	}
+	if tbl.Schema != "sales" {
+		t.Errorf("want sales got %s", tbl.Schema)
+	}
+	if _, found := FindTable(tbls, "crm", "orders"); found {
+		t.Error("crm.orders should not be found")
+	}
+}
+
+func TestFilterTablesSchemaQualified(t *testing.T) {
+	newTbls := func() []*Table {
+		public := &Table{Schema: "public", Name: "orders"}
+		sales := &Table{Schema: "sales", Name: "orders"}
+		customer := &Table{Schema: "public", Name: "customer"}
+		public.ForeingKeys = []*ForeignKey{
+			&ForeignKey{SourceSchemaName: "public", SourceTableName: "orders", TargetSchemaName: "public", TargetTableName: "customer"},
+		}
+		sales.ForeingKeys = []*ForeignKey{
+			&ForeignKey{SourceSchemaName: "sales", SourceTableName: "orders", TargetSchemaName: "public", TargetTableName: "customer"},
+		}
+		return []*Table{public, sales, customer}
+	}
+
+	tbls := FilterTables(true, newTbls(), []string{"sales.orders", "customer"})
+	if len(tbls) != 2 {
+		t.Fatalf("want 2 got %d", len(tbls))
+	}
+	if tbls[0].FullName() != "sales.orders" || tbls[1].FullName() != "public.customer" {
+		t.Errorf("unexpected tables %s, %s", tbls[0].FullName(), tbls[1].FullName())
+	}
+	if len(tbls[0].ForeingKeys) != 1 {
+		t.Errorf("want 1 fk got %d", len(tbls[0].ForeingKeys))
+	}
+
+	tbls = FilterTables(false, newTbls(), []string{"public.orders"})
+	if len(tbls) != 2 {
+		t.Fatalf("want 2 got %d", len(tbls))
+	}
+	if tbls[0].FullName() != "sales.orders" {
+		t.Errorf("want sales.orders got %s", tbls[0].FullName())
+	}
+
+	rel, err := ForeignKeyToUMLRelation(tbls)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "\nsales.orders \"0..N\" -- \"1\" public.customer\n"
+	if string(rel) != expected {
+		t.Errorf("want %q got %q", expected, rel)
+	}
+}
+
 func TestLoadForeignKeyDef(t *testing.T) {
 	conn, cleanup := testPgSetup(t)
 	defer cleanup()
 
 	schema := "public"
-	tbls, err := LoadTableDef(conn, schema)
+	tbls, err := LoadTableDef(context.Background(), conn, schema, "")
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -132,30 +223,31 @@ func TestLoadForeignKeyDef(t *testing.T) {
 	if !found {
 		t.Fatalf("%s not found", n)
 	}
-	fks, err := LoadForeignKeyDef(conn, schema, tbls, tbl)
+	fks, err := LoadForeignKeyDef(context.Background(), conn, schema, tbls, tbl)
 	if err != nil {
 		t.Fatal(err)
 	}
 	expected := []*ForeignKey{
 		&ForeignKey{
-			ConstraintName:        "order_detail_customer_order_id_fkey",
-			SourceTableName:       "order_detail",
-			SourceColName:         "customer_order_id",
-			IsSourceColPrimaryKey: true,
-			TargetTableName:       "customer_order",
-			TargetColName:         "id",
-			IsTargetColPrimaryKey: true,
+			ConstraintName:  "order_detail_customer_order_id_fkey",
+			SourceTableName: "order_detail",
+			TargetTableName: "customer_order",
+			Columns: []*ForeignKeyColumn{
+				&ForeignKeyColumn{SourceColName: "customer_order_id", TargetColName: "id"},
+			},
 		},
 		&ForeignKey{
-			ConstraintName:        "order_detail_sku_id_fkey",
-			SourceTableName:       "order_detail",
-			SourceColName:         "sku_id",
-			IsSourceColPrimaryKey: false,
-			TargetTableName:       "sku",
-			TargetColName:         "id",
-			IsTargetColPrimaryKey: false,
+			ConstraintName:  "order_detail_sku_id_fkey",
+			SourceTableName: "order_detail",
+			TargetTableName: "sku",
+			Columns: []*ForeignKeyColumn{
+				&ForeignKeyColumn{SourceColName: "sku_id", TargetColName: "id"},
+			},
 		},
 	}
+	if len(fks) != len(expected) {
+		t.Fatalf("want %d got %d", len(expected), len(fks))
+	}
 	for i := range fks {
 		fk, exp := fks[i], expected[i]
 		if fk.ConstraintName != exp.ConstraintName {
@@ -164,8 +256,116 @@ func TestLoadForeignKeyDef(t *testing.T) {
 		if fk.SourceTableName != exp.SourceTableName {
 			t.Errorf("wnat %s got %s", exp.SourceTableName, fk.SourceTableName)
 		}
-		if fk.SourceColName != exp.SourceColName {
-			t.Errorf("wnat %s got %s", exp.SourceColName, fk.SourceColName)
+		if fk.TargetTable == nil || fk.TargetTable.Name != exp.TargetTableName {
+			t.Errorf("wnat %s got %+v", exp.TargetTableName, fk.TargetTable)
+		}
+		for j, c := range fk.Columns {
+			if c.SourceColName != exp.Columns[j].SourceColName || c.SourceColumn == nil {
+				t.Errorf("wnat %s got %+v", exp.Columns[j].SourceColName, c)
+			}
+			if c.TargetColName != exp.Columns[j].TargetColName || c.TargetColumn == nil {
+				t.Errorf("wnat %s got %+v", exp.Columns[j].TargetColName, c)
+			}
+		}
+	}
+}
+
+func TestLoadCompositeForeignKeyDef(t *testing.T) {
+	conn, cleanup := testPgSetup(t)
+	defer cleanup()
+
+	schema := "public"
+	tbls, err := LoadTableDef(context.Background(), conn, schema, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := "order_detail_approval"
+	tbl, found := FindTableByName(tbls, n)
+	if !found {
+		t.Fatalf("%s not found", n)
+	}
+	if len(tbl.ForeingKeys) != 1 {
+		t.Fatalf("want 1 got %d", len(tbl.ForeingKeys))
+	}
+	fk := tbl.ForeingKeys[0]
+	expected := "(order_detail_id, customer_order_id) = (id, customer_order_id)"
+	if m := fk.ColumnMapping(); m != expected {
+		t.Errorf("want %s got %s", expected, m)
+	}
+}
+
+func TestForeignKeyCardinality(t *testing.T) {
+	id := &Column{Name: "id", NotNull: true, IsPrimaryKey: true}
+	orderID := &Column{Name: "customer_order_id", NotNull: true, IsPrimaryKey: true}
+	skuID := &Column{Name: "sku_id", NotNull: false}
+	code := &Column{Name: "code", NotNull: true, IsUnique: true}
+	warehouseID := &Column{Name: "warehouse_id", NotNull: true}
+	tbl := &Table{
+		Name:    "order_detail",
+		Columns: []*Column{id, orderID, skuID, code, warehouseID},
+		UniqueGroups: []*UniqueGroup{
+			&UniqueGroup{Name: "order_detail_sku_id_warehouse_id_key", Columns: []string{"sku_id", "warehouse_id"}},
+		},
+	}
+	cases := []struct {
+		cols   []*Column
+		source string
+		target string
+	}{
+		{cols: []*Column{orderID}, source: "0..N", target: "1"},
+		{cols: []*Column{id, orderID}, source: "0..1", target: "1"},
+		{cols: []*Column{skuID}, source: "0..N", target: "0..1"},
+		{cols: []*Column{code}, source: "0..1", target: "1"},
+		{cols: []*Column{warehouseID, skuID}, source: "0..1", target: "0..1"},
+		{cols: []*Column{warehouseID, code}, source: "0..N", target: "1"},
+	}
+	for _, c := range cases {
+		fk := &ForeignKey{SourceTable: tbl}
+		for _, col := range c.cols {
+			fk.Columns = append(fk.Columns, &ForeignKeyColumn{SourceColName: col.Name, SourceColumn: col})
+		}
+		if s := fk.SourceCardinality(); s != c.source {
+			t.Errorf("%s: want %s got %s", fk.ColumnMapping(), c.source, s)
+		}
+		if s := fk.TargetCardinality(); s != c.target {
+			t.Errorf("%s: want %s got %s", fk.ColumnMapping(), c.target, s)
+		}
+	}
+}
+
+func TestTableReferences(t *testing.T) {
+	tbl := &Table{
+		Schema: "public",
+		Name:   "order_detail",
+	}
+	tbl.ForeingKeys = []*ForeignKey{
+		&ForeignKey{
+			SourceTableName:  "order_detail",
+			TargetTableName:  "sku",
+			TargetSchemaName: "public",
+			Columns: []*ForeignKeyColumn{
+				&ForeignKeyColumn{SourceColName: "sku_id", TargetColName: "id"},
+			},
+		},
+		&ForeignKey{
+			SourceTableName:  "order_detail",
+			TargetTableName:  "warehouse",
+			TargetSchemaName: "stock",
+			Columns: []*ForeignKeyColumn{
+				&ForeignKeyColumn{SourceColName: "sku_id", TargetColName: "sku_id"},
+			},
+		},
+	}
+	cases := []struct {
+		col      string
+		expected string
+	}{
+		{col: "sku_id", expected: "sku.id, stock.warehouse.sku_id"},
+		{col: "amount", expected: ""},
+	}
+	for _, c := range cases {
+		if r := tbl.References(c.col); r != c.expected {
+			t.Errorf("want %s got %s", c.expected, r)
 		}
 	}
 }
@@ -175,7 +375,7 @@ func TestLoadTableDef(t *testing.T) {
 	defer cleanup()
 
 	schema := "public"
-	tbls, err := LoadTableDef(conn, schema)
+	tbls, err := LoadTableDef(context.Background(), conn, schema, "")
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -190,36 +390,1575 @@ func TestLoadTableDef(t *testing.T) {
 	}
 }
 
-func TestTableToUMLEntry(t *testing.T) {
+func TestLoadTableDefForSchemasMatchesPerTable(t *testing.T) {
 	conn, cleanup := testPgSetup(t)
 	defer cleanup()
 
 	schema := "public"
-	tbls, err := LoadTableDef(conn, schema)
+	tbls, err := LoadTableDefForSchemas(context.Background(), conn, []string{schema, schema}, "")
 	if err != nil {
 		t.Fatal(err)
 	}
+	seen := make(map[string]bool)
+	for _, tbl := range tbls {
+		if seen[tbl.FullName()] {
+			t.Errorf("%s loaded twice", tbl.FullName())
+		}
+		seen[tbl.FullName()] = true
 
-	buf, err := TableToUMLEntry(tbls)
+		cols, err := LoadColumnDef(context.Background(), conn, schema, tbl.Name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(cols) != len(tbl.Columns) {
+			t.Errorf("%s: want %d columns got %d", tbl.Name, len(cols), len(tbl.Columns))
+		}
+		idxs, err := LoadIndexDef(context.Background(), conn, schema, tbl)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !tbl.IsView() && len(idxs) != len(tbl.Indexes) {
+			t.Errorf("%s: want %d indexes got %d", tbl.Name, len(idxs), len(tbl.Indexes))
+		}
+		fks, err := LoadForeignKeyDef(context.Background(), conn, schema, tbls, tbl)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(fks) != len(tbl.ForeingKeys) {
+			t.Errorf("%s: want %d fks got %d", tbl.Name, len(fks), len(tbl.ForeingKeys))
+		}
+	}
+}
+
+func TestOpenSessionStatementTimeout(t *testing.T) {
+	conn, cleanup := testPgSetup(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	sess, err := OpenSession(ctx, conn, 2*time.Second)
 	if err != nil {
 		t.Fatal(err)
 	}
-	t.Logf("%s", buf)
+	defer sess.Close()
+	var v string
+	if err := sess.QueryRowContext(ctx, "SHOW statement_timeout").Scan(&v); err != nil {
+		t.Fatal(err)
+	}
+	if v != "2s" {
+		t.Errorf("want 2s got %s", v)
+	}
 }
 
-func TestForeignKeyToUMLRelation(t *testing.T) {
+func TestBeginSnapshot(t *testing.T) {
+	conn, cleanup := testPgSetup(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	sess, err := OpenSession(ctx, conn, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sess.Close()
+	tx, err := BeginSnapshot(ctx, sess, "", "planter", "public")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tx.Rollback()
+	cases := []struct {
+		query    string
+		expected string
+	}{
+		{query: "SHOW transaction_isolation", expected: "repeatable read"},
+		{query: "SHOW transaction_read_only", expected: "on"},
+		{query: "SHOW search_path", expected: "public"},
+		{query: "SELECT current_user", expected: "planter"},
+	}
+	for _, c := range cases {
+		var v string
+		if err := tx.QueryRowContext(ctx, c.query).Scan(&v); err != nil {
+			t.Fatal(err)
+		}
+		if v != c.expected {
+			t.Errorf("%s: want %s got %s", c.query, c.expected, v)
+		}
+	}
+	if _, err := LoadTableDef(ctx, tx, "public", ""); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLoadTableDefCanceled(t *testing.T) {
+	conn, cleanup := testPgSetup(t)
+	defer cleanup()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := LoadTableDef(ctx, conn, "public", ""); err == nil {
+		t.Error("want error for canceled context")
+	}
+}
+
+func TestRunJobs(t *testing.T) {
+	dbs := make([]QueryerContext, 3)
+	done := make([]bool, 10)
+	var jobs []fetchJob
+	for i := range done {
+		i := i
+		jobs = append(jobs, func(ctx context.Context, db QueryerContext) error {
+			done[i] = true
+			return nil
+		})
+	}
+	if err := runJobs(context.Background(), dbs, jobs); err != nil {
+		t.Fatal(err)
+	}
+	for i, d := range done {
+		if !d {
+			t.Errorf("job %d not run", i)
+		}
+	}
+
+	failed := errors.New("failed")
+	jobs = append(jobs, func(ctx context.Context, db QueryerContext) error {
+		return failed
+	})
+	if err := runJobs(context.Background(), dbs, jobs); err != failed {
+		t.Errorf("want %v got %v", failed, err)
+	}
+}
+
+func TestLoadTableDefParallel(t *testing.T) {
+	conn, cleanup := testPgSetup(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	render := func(tbls []*Table) string {
+		entry, err := TableToUMLEntry(tbls)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rel, err := ForeignKeyToUMLRelation(tbls)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(entry) + string(rel)
+	}
+	seq, err := LoadTableDefForSchemas(ctx, conn, []string{"public"}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	par, err := LoadTableDefParallel(ctx, []QueryerContext{conn, conn, conn}, []string{"public"}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s, p := render(seq), render(par); s != p {
+		t.Errorf("parallel output differs\nwant %s\ngot %s", s, p)
+	}
+}
+
+func TestLoadTableDefParallelSchemas(t *testing.T) {
+	conn, cleanup := testPgSetup(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	for _, stmt := range []string{
+		`create schema if not exists planter_billing`,
+		`create table if not exists planter_billing.invoice (
+		  id bigserial primary key
+		  , customer_id bigint not null references public.customer (id)
+		)`,
+		`create table if not exists planter_billing.account (
+		  id bigserial primary key
+		  , invoice_id bigint references planter_billing.invoice (id)
+		)`,
+	} {
+		if _, err := conn.Exec(stmt); err != nil {
+			t.Fatal(err)
+		}
+	}
+	defer conn.Exec(`drop schema planter_billing cascade`)
+
+	render := func(tbls []*Table) string {
+		entry, err := TableToUMLEntry(tbls)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rel, err := ForeignKeyToUMLRelation(tbls)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(entry) + string(rel)
+	}
+	schemas := []string{"planter_billing", "public"}
+	seq, err := LoadTableDefForSchemas(ctx, conn, schemas, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	par, err := LoadTableDefParallel(ctx, []QueryerContext{conn, conn, conn}, schemas, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s, p := render(seq), render(par); s != p {
+		t.Errorf("parallel output differs\nwant %s\ngot %s", s, p)
+	}
+	if seq[0].Schema != "planter_billing" || seq[len(seq)-1].Schema != "public" {
+		t.Errorf("want tables in requested schema order got %s ... %s", seq[0].FullName(), seq[len(seq)-1].FullName())
+	}
+	invoice, found := FindTable(seq, "planter_billing", "invoice")
+	if !found || len(invoice.ForeingKeys) != 1 || invoice.ForeingKeys[0].TargetTable == nil {
+		t.Errorf("want invoice fk resolved to public.customer got %+v", invoice)
+	}
+}
+
+func TestLoadTableDefViews(t *testing.T) {
 	conn, cleanup := testPgSetup(t)
 	defer cleanup()
 
 	schema := "public"
-	tbls, err := LoadTableDef(conn, schema)
+	tbls, err := LoadTableDef(context.Background(), conn, schema, "")
 	if err != nil {
 		t.Fatal(err)
 	}
+	cases := []struct {
+		name string
+		kind string
+	}{
+		{name: "customer", kind: KindTable},
+		{name: "customer_order_summary", kind: KindView},
+		{name: "vendor_product_count", kind: KindMaterializedView},
+	}
+	for _, c := range cases {
+		tbl, found := FindTableByName(tbls, c.name)
+		if !found {
+			t.Fatalf("%s not found", c.name)
+		}
+		if tbl.Kind != c.kind {
+			t.Errorf("want %s got %s", c.kind, tbl.Kind)
+		}
+		if len(tbl.Columns) == 0 {
+			t.Errorf("%s has no columns", c.name)
+		}
+	}
 
-	buf, err := ForeignKeyToUMLRelation(tbls)
+	tbls, err = LoadTableDef(context.Background(), conn, schema, "v")
 	if err != nil {
 		t.Fatal(err)
 	}
-	t.Logf("%s", buf)
+	for _, tbl := range tbls {
+		if tbl.Kind != KindTable {
+			t.Errorf("%s should be skipped", tbl.Name)
+		}
+	}
+}
+
+func TestLoadPartitionDef(t *testing.T) {
+	conn, cleanup := testPgSetup(t)
+	defer cleanup()
+
+	schema := "public"
+	tbls, err := LoadTableDef(context.Background(), conn, schema, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := "customer_event"
+	tbl, found := FindTableByName(tbls, n)
+	if !found {
+		t.Fatalf("%s not found", n)
+	}
+	if !tbl.IsPartitioned() {
+		t.Errorf("%s should be partitioned", n)
+	}
+	if e := "RANGE (created_at)"; tbl.PartitionKey.String != e {
+		t.Errorf("want %s got %s", e, tbl.PartitionKey.String)
+	}
+	expected := []string{"customer_event_2024_01", "customer_event_2024_02"}
+	if len(tbl.Partitions) != len(expected) {
+		t.Fatalf("want %d got %d", len(expected), len(tbl.Partitions))
+	}
+	for i, p := range tbl.Partitions {
+		if p.Name != expected[i] {
+			t.Errorf("want %s got %s", expected[i], p.Name)
+		}
+		if p.Table == nil || !p.Table.IsPartition {
+			t.Errorf("%s should be linked to a partition table", p.Name)
+		}
+	}
+	for _, tbl := range FilterPartitions(tbls) {
+		if tbl.IsPartition {
+			t.Errorf("%s should be filtered", tbl.Name)
+		}
+	}
+}
+
+func TestLoadInheritanceDef(t *testing.T) {
+	conn, cleanup := testPgSetup(t)
+	defer cleanup()
+
+	schema := "public"
+	tbls, err := LoadTableDef(context.Background(), conn, schema, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := "corporate_customer"
+	tbl, found := FindTableByName(tbls, n)
+	if !found {
+		t.Fatalf("%s not found", n)
+	}
+	if len(tbl.Inherits) != 1 {
+		t.Fatalf("want 1 got %d", len(tbl.Inherits))
+	}
+	if p := tbl.Inherits[0].ParentTable; p == nil || p.Name != "customer" {
+		t.Errorf("want customer got %+v", p)
+	}
+	tbls = HideInheritedColumns(tbls)
+	expected := []string{"company_name", "tax_number"}
+	if len(tbl.Columns) != len(expected) {
+		t.Fatalf("want %d got %d", len(expected), len(tbl.Columns))
+	}
+	for i, c := range tbl.Columns {
+		if c.Name != expected[i] {
+			t.Errorf("want %s got %s", expected[i], c.Name)
+		}
+	}
+}
+
+func TestInheritanceToUMLRelation(t *testing.T) {
+	tbl := &Table{
+		Schema: "public",
+		Name:   "corporate_customer",
+		Inherits: []*Inheritance{
+			&Inheritance{ParentSchemaName: "public", ParentTableName: "customer"},
+			&Inheritance{ParentSchemaName: "crm", ParentTableName: "account"},
+		},
+	}
+	schemaSrc, globalSrc, err := InheritanceToUMLRelation2(tbl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e := "\npublic.customer <|-- public.corporate_customer\n"; string(schemaSrc) != e {
+		t.Errorf("want %q got %q", e, schemaSrc)
+	}
+	if e := "\ncrm.account <|-- public.corporate_customer\n"; string(globalSrc) != e {
+		t.Errorf("want %q got %q", e, globalSrc)
+	}
+}
+
+func TestLoadIndexDef(t *testing.T) {
+	conn, cleanup := testPgSetup(t)
+	defer cleanup()
+
+	schema := "public"
+	tbls, err := LoadTableDef(context.Background(), conn, schema, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := "product"
+	tbl, found := FindTableByName(tbls, n)
+	if !found {
+		t.Fatalf("%s not found", n)
+	}
+	expected := []*Index{
+		&Index{
+			Name:      "product_lower_name_idx",
+			Method:    "btree",
+			Columns:   []string{"lower(name)"},
+			Predicate: sql.NullString{String: "country = 'JP'::text", Valid: true},
+		},
+		&Index{
+			Name:      "product_pkey",
+			Method:    "btree",
+			IsUnique:  true,
+			IsPrimary: true,
+			Columns:   []string{"id"},
+		},
+	}
+	if len(tbl.Indexes) != len(expected) {
+		t.Fatalf("want %d got %d", len(expected), len(tbl.Indexes))
+	}
+	for i := range tbl.Indexes {
+		if !reflect.DeepEqual(tbl.Indexes[i], expected[i]) {
+			t.Errorf("\n%+v\n%+v", tbl.Indexes[i], expected[i])
+		}
+	}
+}
+
+func TestLoadUniqueDef(t *testing.T) {
+	conn, cleanup := testPgSetup(t)
+	defer cleanup()
+
+	schema := "public"
+	tbls, err := LoadTableDef(context.Background(), conn, schema, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := "sku"
+	tbl, found := FindTableByName(tbls, n)
+	if !found {
+		t.Fatalf("%s not found", n)
+	}
+	expected := []*UniqueGroup{
+		&UniqueGroup{Name: "sku_product_color_size_key", Columns: []string{"product_id", "color", "size"}},
+	}
+	if !reflect.DeepEqual(tbl.UniqueGroups, expected) {
+		t.Errorf("want %+v got %+v", expected, tbl.UniqueGroups)
+	}
+	for _, c := range tbl.Columns {
+		if c.IsUnique {
+			t.Errorf("%s should not be unique on its own", c.Name)
+		}
+	}
+}
+
+func TestTableUniqueMarkers(t *testing.T) {
+	tbl := &Table{
+		Schema: "public",
+		Name:   "account",
+		Columns: []*Column{
+			&Column{Name: "id", DataType: "BIGINT", NotNull: true, IsPrimaryKey: true},
+			&Column{Name: "tenant_id", DataType: "BIGINT", NotNull: true},
+			&Column{Name: "email", DataType: "TEXT", NotNull: true},
+			&Column{Name: "code", DataType: "TEXT", IsUnique: true},
+		},
+		UniqueGroups: []*UniqueGroup{
+			&UniqueGroup{Name: "account_tenant_id_email_key", Columns: []string{"tenant_id", "email"}},
+			&UniqueGroup{Name: "account_tenant_id_code_key", Columns: []string{"tenant_id", "code"}},
+		},
+	}
+	cases := []struct {
+		col      string
+		expected string
+	}{
+		{col: "tenant_id", expected: "UN1 UN2"},
+		{col: "email", expected: "UN1"},
+		{col: "code", expected: "UN2"},
+		{col: "id", expected: ""},
+	}
+	for _, c := range cases {
+		if m := tbl.UniqueMarkers(c.col); m != c.expected {
+			t.Errorf("%s: want %q got %q", c.col, c.expected, m)
+		}
+	}
+	src, err := TableToUMLTable(tbl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range []string{
+		"  tenant_id: BIGINT NN UN1 UN2\n",
+		"  email: TEXT NN UN1\n",
+		"  code: TEXT UN UN2\n",
+	} {
+		if !strings.Contains(string(src), line) {
+			t.Errorf("%q not found in\n%s", line, src)
+		}
+	}
+	rst, err := TableToRSTTable(tbl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range []string{
+		`   "UN1", "account_tenant_id_email_key", "tenant_id, email"` + "\n",
+		`   "UN2", "account_tenant_id_code_key", "tenant_id, code"` + "\n",
+	} {
+		if !strings.Contains(string(rst), line) {
+			t.Errorf("%q not found in\n%s", line, rst)
+		}
+	}
+}
+
+func TestLoadConstraintDef(t *testing.T) {
+	conn, cleanup := testPgSetup(t)
+	defer cleanup()
+
+	schema := "public"
+	tbls, err := LoadTableDef(context.Background(), conn, schema, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := "sku"
+	tbl, found := FindTableByName(tbls, n)
+	if !found {
+		t.Fatalf("%s not found", n)
+	}
+	expected := []*Constraint{
+		&Constraint{
+			Name:       "sku_price_check",
+			Type:       ConstraintCheck,
+			Definition: "CHECK (sales_unit_price >= purchase_unit_price)",
+			Columns:    []string{"sales_unit_price", "purchase_unit_price"},
+		},
+	}
+	if len(tbl.Constraints) != len(expected) {
+		t.Fatalf("want %d got %d", len(expected), len(tbl.Constraints))
+	}
+	for i := range tbl.Constraints {
+		if !reflect.DeepEqual(tbl.Constraints[i], expected[i]) {
+			t.Errorf("\n%+v\n%+v", tbl.Constraints[i], expected[i])
+		}
+	}
+}
+
+func TestLoadColumnDefIdentityAndGenerated(t *testing.T) {
+	conn, cleanup := testPgSetup(t)
+	defer cleanup()
+
+	schema := "public"
+	tbls, err := LoadTableDef(context.Background(), conn, schema, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := "order_detail_approval"
+	tbl, found := FindTableByName(tbls, n)
+	if !found {
+		t.Fatalf("%s not found", n)
+	}
+	if tbl.AutoGenPk {
+		t.Errorf("%s should not have auto generated pk", n)
+	}
+	cols := make(map[string]*Column)
+	for _, c := range tbl.Columns {
+		cols[c.Name] = c
+	}
+	if c := cols["id"]; c == nil || c.IdentityKind != "ALWAYS" || !c.SequenceName.Valid || c.IsSerial() {
+		t.Errorf("id should be identity: %+v", c)
+	}
+	if c := cols["approved_at"]; c == nil || c.DefVal.String != "now()" {
+		t.Errorf("approved_at should default to now(): %+v", c)
+	}
+	if c := cols["approval_key"]; c == nil || !c.IsGenerated() || c.DefVal.Valid {
+		t.Errorf("approval_key should be generated: %+v", c)
+	}
+
+	n = "customer"
+	tbl, found = FindTableByName(tbls, n)
+	if !found {
+		t.Fatalf("%s not found", n)
+	}
+	if !tbl.AutoGenPk {
+		t.Errorf("%s should have auto generated pk", n)
+	}
+}
+
+func TestLoadColumnDefEnum(t *testing.T) {
+	conn, cleanup := testPgSetup(t)
+	defer cleanup()
+
+	cols, err := LoadColumnDef(context.Background(), conn, "public", "customer_order")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var col *Column
+	for _, c := range cols {
+		if c.Name == "delivery_method" {
+			col = c
+		}
+	}
+	if col == nil {
+		t.Fatal("delivery_method not found")
+	}
+	expected := &Enum{
+		Schema: "public",
+		Name:   "delivery_method",
+		Values: []string{"standard", "express", "pickup"},
+	}
+	if !reflect.DeepEqual(col.Enum, expected) {
+		t.Errorf("\n%+v\n%+v", col.Enum, expected)
+	}
+}
+
+func TestLoadColumnDefDomainAndComposite(t *testing.T) {
+	conn, cleanup := testPgSetup(t)
+	defer cleanup()
+
+	cols, err := LoadColumnDef(context.Background(), conn, "public", "vendor")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var phone, address *Column
+	for _, c := range cols {
+		switch c.Name {
+		case "phone_number":
+			phone = c
+		case "billing_address":
+			address = c
+		}
+	}
+	if phone == nil || address == nil {
+		t.Fatalf("columns not found: %+v", cols)
+	}
+	expectedDomain := &Domain{
+		Schema:   "public",
+		Name:     "phone_number",
+		BaseType: "TEXT",
+		NotNull:  true,
+		Checks:   []string{"CHECK (VALUE ~ '^[0-9-]+$'::text)"},
+	}
+	if !reflect.DeepEqual(phone.Domain, expectedDomain) {
+		t.Errorf("\n%+v\n%+v", phone.Domain, expectedDomain)
+	}
+	if address.Composite == nil {
+		t.Fatal("billing_address should be composite")
+	}
+	if e := "zip_code TEXT, line1 TEXT, line2 TEXT"; address.Composite.AttributeList() != e {
+		t.Errorf("want %s got %s", e, address.Composite.AttributeList())
+	}
+}
+
+func TestTypeToUMLEntry(t *testing.T) {
+	domains := []*Domain{
+		&Domain{Schema: "public", Name: "phone_number", BaseType: "TEXT", NotNull: true, Checks: []string{"CHECK (VALUE <> '')"}},
+	}
+	types := []*CompositeType{
+		&CompositeType{Schema: "public", Name: "postal_address", Attributes: []*CompositeAttribute{
+			&CompositeAttribute{Name: "zip_code", DataType: "TEXT"},
+		}},
+	}
+	src, err := TypeToUMLEntry(domains, types)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `
+class "phone_number" as public.phone_number << (D,#DDEEFF) domain >> {
+  TEXT NN
+  {field} CHECK (VALUE <> '')
+}
+
+class "postal_address" as public.postal_address << (C,#DDEEFF) composite >> {
+  zip_code: TEXT
+}
+`
+	if string(src) != expected {
+		t.Errorf("want %q got %q", expected, src)
+	}
+}
+
+func TestTableEnums(t *testing.T) {
+	e := &Enum{Schema: "public", Name: "delivery_method", Values: []string{"standard", "express"}}
+	tbls := []*Table{
+		&Table{Name: "customer_order", Columns: []*Column{
+			&Column{Name: "id"},
+			&Column{Name: "delivery_method", Enum: e},
+		}},
+		&Table{Name: "shipment", Columns: []*Column{
+			&Column{Name: "delivery_method", Enum: &Enum{Schema: "public", Name: "delivery_method"}},
+		}},
+	}
+	enums := TableEnums(tbls)
+	if len(enums) != 1 || enums[0] != e {
+		t.Fatalf("want [%+v] got %+v", e, enums)
+	}
+	src, err := EnumToUMLEntry(enums)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := "\nenum \"delivery_method\" as public.delivery_method {\n  standard\n  express\n}\n"; string(src) != expected {
+		t.Errorf("want %q got %q", expected, src)
+	}
+}
+
+func TestLoadTriggerDef(t *testing.T) {
+	conn, cleanup := testPgSetup(t)
+	defer cleanup()
+
+	schema := "public"
+	tbls, err := LoadTableDef(context.Background(), conn, schema, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := "customer_order"
+	tbl, found := FindTableByName(tbls, n)
+	if !found {
+		t.Fatalf("%s not found", n)
+	}
+	expected := []*Trigger{
+		&Trigger{
+			Name:           "customer_order_ordered_at",
+			Timing:         "BEFORE",
+			Events:         []string{"INSERT"},
+			Level:          "ROW",
+			FunctionSchema: "public",
+			FunctionName:   "set_ordered_at",
+			Enabled:        true,
+		},
+	}
+	if len(tbl.Triggers) != len(expected) {
+		t.Fatalf("want %d got %d", len(expected), len(tbl.Triggers))
+	}
+	for i := range tbl.Triggers {
+		if !reflect.DeepEqual(tbl.Triggers[i], expected[i]) {
+			t.Errorf("\n%+v\n%+v", tbl.Triggers[i], expected[i])
+		}
+	}
+}
+
+func TestLoadEnumDef(t *testing.T) {
+	conn, cleanup := testPgSetup(t)
+	defer cleanup()
+
+	enums, err := LoadEnumDef(context.Background(), conn, "public")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []*Enum{
+		&Enum{Schema: "public", Name: "delivery_method", Values: []string{"standard", "express", "pickup"}},
+	}
+	if !reflect.DeepEqual(enums, expected) {
+		t.Errorf("want %+v got %+v", expected, enums)
+	}
+}
+
+func TestLoadFunctionDef(t *testing.T) {
+	conn, cleanup := testPgSetup(t)
+	defer cleanup()
+
+	fns, err := LoadFunctionDef(context.Background(), conn, "public")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []*Function{
+		&Function{
+			Schema:     "public",
+			Name:       "set_ordered_at",
+			Kind:       "function",
+			Result:     sql.NullString{String: "trigger", Valid: true},
+			Language:   "plpgsql",
+			Volatility: "volatile",
+			Comment:    sql.NullString{String: "Stamp order time on insert", Valid: true},
+		},
+	}
+	if len(fns) != len(expected) {
+		t.Fatalf("want %d got %d", len(expected), len(fns))
+	}
+	for i := range fns {
+		if !reflect.DeepEqual(fns[i], expected[i]) {
+			t.Errorf("\n%+v\n%+v", fns[i], expected[i])
+		}
+	}
+}
+
+func TestTriggerFunctionToUML(t *testing.T) {
+	tg := &Trigger{Name: "stamp", FunctionSchema: "public", FunctionName: "set_ordered_at"}
+	tbls := []*Table{
+		&Table{Name: "customer_order", Triggers: []*Trigger{tg}},
+		&Table{Name: "order_detail", Triggers: []*Trigger{tg}},
+	}
+	entry, rel, err := TriggerFunctionToUML(tbls)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e := "\ncomponent \"public.set_ordered_at()\" as fn_public_set_ordered_at\n"; string(entry) != e {
+		t.Errorf("want %q got %q", e, entry)
+	}
+	e := "\nfn_public_set_ordered_at ..> customer_order : stamp\n" +
+		"\nfn_public_set_ordered_at ..> order_detail : stamp\n"
+	if string(rel) != e {
+		t.Errorf("want %q got %q", e, rel)
+	}
+}
+
+func TestLoadPolicyAndGrantDef(t *testing.T) {
+	conn, cleanup := testPgSetup(t)
+	defer cleanup()
+
+	schema := "public"
+	tbls, err := LoadTableDef(context.Background(), conn, schema, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := "customer_order"
+	tbl, found := FindTableByName(tbls, n)
+	if !found {
+		t.Fatalf("%s not found", n)
+	}
+	if !tbl.RowSecurity || tbl.ForceRowSecurity {
+		t.Errorf("want row security enabled and not forced got %v %v", tbl.RowSecurity, tbl.ForceRowSecurity)
+	}
+	expected := []*Policy{
+		&Policy{
+			Name:       "customer_order_owner",
+			Command:    "SELECT",
+			Permissive: true,
+			Roles:      []string{"PUBLIC"},
+			Using:      sql.NullString{String: "customer_id = current_setting('app.customer_id'::text)::bigint", Valid: true},
+		},
+	}
+	if len(tbl.Policies) != len(expected) {
+		t.Fatalf("want %d got %d", len(expected), len(tbl.Policies))
+	}
+	for i := range tbl.Policies {
+		if !reflect.DeepEqual(tbl.Policies[i], expected[i]) {
+			t.Errorf("\n%+v\n%+v", tbl.Policies[i], expected[i])
+		}
+	}
+	var public *Grant
+	for _, g := range tbl.Grants {
+		if g.Grantee == "PUBLIC" {
+			public = g
+		}
+	}
+	if public == nil || public.Column != "" || public.Privileges != "SELECT" {
+		t.Errorf("want SELECT granted to PUBLIC got %+v", public)
+	}
+}
+
+func TestColorBySelectRoles(t *testing.T) {
+	roles := []string{"analyst", "marketing"}
+	tbls := []*Table{
+		&Table{Name: "customer", SelectRoles: []string{"analyst", "marketing"}},
+		&Table{Name: "vendor", SelectRoles: []string{"analyst"}},
+		&Table{Name: "sku"},
+	}
+	tbls = ColorBySelectRoles(tbls, roles)
+	expected := []string{"#FFFFAA", "#AAFFAA", "#FFFFFF"}
+	for i, tbl := range tbls {
+		if tbl.Color != expected[i] {
+			t.Errorf("%s: want %s got %s", tbl.Name, expected[i], tbl.Color)
+		}
+	}
+	legend := `    SELECT granted to
+    <back:#FFFFFF>    </back> none of analyst, marketing
+    <back:#AAFFAA>    </back> analyst
+    <back:#FFFFAA>    </back> analyst, marketing
+`
+	if l := SelectRoleLegend(tbls, roles); string(l) != legend {
+		t.Errorf("want %q got %q", legend, l)
+	}
+}
+
+func TestLoadTableStats(t *testing.T) {
+	conn, cleanup := testPgSetup(t)
+	defer cleanup()
+
+	schema := "public"
+	tbls, err := LoadTableDef(context.Background(), conn, schema, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := LoadTableStats(context.Background(), conn, schema, tbls); err != nil {
+		t.Fatal(err)
+	}
+	for _, tbl := range tbls {
+		if tbl.IsView() {
+			if tbl.Stats != nil {
+				t.Errorf("%s should not have stats", tbl.Name)
+			}
+			continue
+		}
+		if tbl.Stats == nil {
+			t.Errorf("%s should have stats", tbl.Name)
+		}
+	}
+}
+
+func TestTableStatsSize(t *testing.T) {
+	cases := []struct {
+		bytes int64
+		size  string
+		class string
+	}{
+		{bytes: 8192, size: "8.0 kB", class: "small"},
+		{bytes: 512, size: "512 bytes", class: "small"},
+		{bytes: 200 * 1024 * 1024, size: "200.0 MB", class: "medium"},
+		{bytes: 12 * 1024 * 1024 * 1024, size: "12.0 GB", class: "huge"},
+	}
+	for _, c := range cases {
+		st := &TableStats{TotalBytes: c.bytes}
+		if s := st.TotalSize(); s != c.size {
+			t.Errorf("want %s got %s", c.size, s)
+		}
+		if s := st.SizeClass(); s != c.class {
+			t.Errorf("want %s got %s", c.class, s)
+		}
+	}
+}
+
+func TestLoadColumnStats(t *testing.T) {
+	conn, cleanup := testPgSetup(t)
+	defer cleanup()
+
+	schema := "public"
+	if _, err := conn.Exec("ANALYZE customer"); err != nil {
+		t.Fatal(err)
+	}
+	tbls, err := LoadTableDef(context.Background(), conn, schema, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := LoadColumnStats(context.Background(), conn, schema, tbls, false); err != nil {
+		t.Fatal(err)
+	}
+	tbl, found := FindTableByName(tbls, "customer")
+	if !found {
+		t.Fatal("customer not found")
+	}
+	for _, c := range tbl.Columns {
+		if c.Stats != nil && len(c.Stats.MostCommonVals) != 0 {
+			t.Errorf("%s: most common values should not be loaded", c.Name)
+		}
+	}
+}
+
+func TestColumnStatsDistinct(t *testing.T) {
+	cases := []struct {
+		nDistinct float64
+		expected  string
+	}{
+		{nDistinct: -1, expected: "unique"},
+		{nDistinct: -0.25, expected: "25.0% of rows"},
+		{nDistinct: 42, expected: "42"},
+	}
+	for _, c := range cases {
+		st := &ColumnStats{NDistinct: c.nDistinct}
+		if d := st.Distinct(); d != c.expected {
+			t.Errorf("want %s got %s", c.expected, d)
+		}
+	}
+}
+
+func TestTableToUMLEntry(t *testing.T) {
+	conn, cleanup := testPgSetup(t)
+	defer cleanup()
+
+	schema := "public"
+	tbls, err := LoadTableDef(context.Background(), conn, schema, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	buf, err := TableToUMLEntry(tbls)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Logf("%s", buf)
+}
+
+func TestForeignKeyToUMLRelation(t *testing.T) {
+	conn, cleanup := testPgSetup(t)
+	defer cleanup()
+
+	schema := "public"
+	tbls, err := LoadTableDef(context.Background(), conn, schema, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	buf, err := ForeignKeyToUMLRelation(tbls)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Logf("%s", buf)
+}
+
+func TestParseDDL(t *testing.T) {
+	src, err := ioutil.ReadFile("./example/ddl.sql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tbls, err := ParseDDL(string(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tbls) != 14 {
+		t.Fatalf("want 14 tables got %d", len(tbls))
+	}
+
+	customer, _ := FindTable(tbls, "public", "customer")
+	if !customer.AutoGenPk || customer.Comment.String != "Customer Information" {
+		t.Errorf("unexpected customer: %+v", customer)
+	}
+	id := customer.Columns[0]
+	if id.DDLType != "bigserial" || !id.IsSerial() || !id.IsPrimaryKey {
+		t.Errorf("unexpected customer.id: %+v", id)
+	}
+
+	sku, _ := FindTable(tbls, "public", "sku")
+	if len(sku.UniqueGroups) != 1 || sku.UniqueMarkers("color") != "UN1" {
+		t.Errorf("unexpected sku unique groups: %+v", sku.UniqueGroups)
+	}
+	if len(sku.Constraints) != 1 || sku.Constraints[0].ColumnList() != "sales_unit_price, purchase_unit_price" {
+		t.Errorf("unexpected sku constraints: %+v", sku.Constraints)
+	}
+
+	approval, _ := FindTable(tbls, "public", "order_detail_approval")
+	fk := approval.ForeingKeys[0]
+	if fk.TargetTable == nil || fk.ColumnMapping() != "(order_detail_id, customer_order_id) = (id, customer_order_id)" {
+		t.Errorf("unexpected foreign key: %+v", fk)
+	}
+	if c := approval.Columns[0]; c.IdentityKind != "ALWAYS" {
+		t.Errorf("want identity column got %+v", c)
+	}
+
+	order, _ := FindTable(tbls, "public", "customer_order")
+	if order.Columns[2].Enum == nil || !order.RowSecurity || len(order.Triggers) != 1 || len(order.Policies) != 1 {
+		t.Errorf("unexpected customer_order: %+v", order)
+	}
+	vendor, _ := FindTable(tbls, "public", "vendor")
+	if vendor.Columns[2].Domain == nil || vendor.Columns[3].Composite == nil {
+		t.Errorf("unexpected vendor types: %+v %+v", vendor.Columns[2], vendor.Columns[3])
+	}
+
+	event, _ := FindTable(tbls, "public", "customer_event")
+	if !event.IsPartitioned() || event.PartitionKey.String != "RANGE (created_at)" || len(event.Partitions) != 2 {
+		t.Errorf("unexpected customer_event: %+v", event)
+	}
+	if p := event.Partitions[0]; p.Table == nil || p.Bound != "FOR VALUES FROM ('2024-01-01') TO ('2024-02-01')" {
+		t.Errorf("unexpected partition: %+v", p)
+	}
+
+	corporate, _ := FindTable(tbls, "public", "corporate_customer")
+	if len(corporate.Columns) != 8 || !corporate.Columns[0].IsInherited || corporate.Inherits[0].ParentTable != customer {
+		t.Errorf("unexpected corporate_customer: %+v", corporate)
+	}
+
+	summary, _ := FindTable(tbls, "public", "customer_order_summary")
+	var names []string
+	for _, c := range summary.Columns {
+		names = append(names, c.Name)
+	}
+	if strings.Join(names, ",") != "customer_id,name,order_count,total_price" {
+		t.Errorf("unexpected view columns: %v", names)
+	}
+}
+
+func TestParseDDLDump(t *testing.T) {
+	src := `
+SET statement_timeout = 0;
+SELECT pg_catalog.set_config('search_path', '', false);
+CREATE TYPE app.status AS ENUM (
+    'active',
+    'it''s done'
+);
+CREATE FUNCTION app.touch() RETURNS trigger
+    LANGUAGE plpgsql
+    AS $_$begin new.updated_at := now(); return new; end;$_$;
+CREATE TABLE app."user" (
+    id integer NOT NULL,
+    email character varying(255) NOT NULL,
+    status app.status DEFAULT 'active'::app.status NOT NULL
+);
+COMMENT ON COLUMN app."user".email IS 'Login e-mail';
+CREATE SEQUENCE app.user_id_seq AS integer START WITH 1;
+ALTER SEQUENCE app.user_id_seq OWNED BY app."user".id;
+CREATE TABLE app.post (
+    id bigint NOT NULL,
+    user_id integer,
+    title text
+);
+ALTER TABLE app.post ALTER COLUMN id ADD GENERATED ALWAYS AS IDENTITY (
+    SEQUENCE NAME app.post_id_seq
+);
+ALTER TABLE ONLY app."user" ALTER COLUMN id SET DEFAULT nextval('app.user_id_seq'::regclass);
+ALTER TABLE ONLY app."user"
+    ADD CONSTRAINT user_pkey PRIMARY KEY (id);
+ALTER TABLE ONLY app."user"
+    ADD CONSTRAINT user_email_key UNIQUE (email);
+ALTER TABLE ONLY app.post
+    ADD CONSTRAINT post_pkey PRIMARY KEY (id);
+CREATE INDEX post_title_idx ON app.post USING gin (to_tsvector('english'::regconfig, title));
+CREATE TRIGGER post_touch BEFORE UPDATE ON app.post FOR EACH ROW EXECUTE FUNCTION app.touch();
+ALTER TABLE ONLY app.post
+    ADD CONSTRAINT post_user_id_fkey FOREIGN KEY (user_id) REFERENCES app."user"(id) ON DELETE SET NULL;
+`
+	tbls, err := ParseDDL(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	user, found := FindTable(tbls, "app", "user")
+	if !found {
+		t.Fatalf("app.user not found in %+v", tbls)
+	}
+	id, email, status := user.Columns[0], user.Columns[1], user.Columns[2]
+	if id.DDLType != "serial" || !id.IsPrimaryKey || !user.AutoGenPk {
+		t.Errorf("unexpected user.id: %+v", id)
+	}
+	if email.DataType != "CHARACTER VARYING(255)" || !email.IsUnique || email.Comment.String != "Login e-mail" {
+		t.Errorf("unexpected user.email: %+v", email)
+	}
+	if status.Enum == nil || !reflect.DeepEqual(status.Enum.Values, []string{"active", "it's done"}) {
+		t.Errorf("unexpected user.status: %+v", status)
+	}
+
+	post, _ := FindTable(tbls, "app", "post")
+	if !post.AutoGenPk || post.Columns[0].IdentityKind != "ALWAYS" {
+		t.Errorf("unexpected post.id: %+v", post.Columns[0])
+	}
+	if fk := post.ForeingKeys[0]; fk.TargetTable != user || !post.Columns[1].IsForeignKey {
+		t.Errorf("unexpected foreign key: %+v", fk)
+	}
+	if idx := post.Indexes[1]; idx.Method != "gin" || idx.ColumnList() != "to_tsvector('english'::regconfig, title)" {
+		t.Errorf("unexpected index: %+v", idx)
+	}
+	if tg := post.Triggers[0]; tg.Function() != "app.touch" || tg.Level != "ROW" {
+		t.Errorf("unexpected trigger: %+v", tg)
+	}
+}
+
+func TestLoadDDLSkipFlags(t *testing.T) {
+	tbls, err := LoadDDL("./example", []string{"public"}, "vf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tbl := range tbls {
+		if tbl.IsView() || tbl.IsMaterializedView() {
+			t.Errorf("view %s not skipped", tbl.Name)
+		}
+		if len(tbl.ForeingKeys) != 0 {
+			t.Errorf("foreign keys of %s not skipped", tbl.Name)
+		}
+	}
+	if len(tbls) != 12 {
+		t.Errorf("want 12 tables got %d", len(tbls))
+	}
+}
+
+func TestSnapshotRoundTrip(t *testing.T) {
+	src, err := ioutil.ReadFile("./example/ddl.sql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tbls, err := ParseDDL(string(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := WriteSnapshot(&buf, []string{"public"}, tbls, nil); err != nil {
+		t.Fatal(err)
+	}
+	s, err := ReadSnapshot(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	restored := schemaTables(s.Tables, []string{"public"}, "")
+	relinkTables(restored)
+
+	render := func(tbls []*Table) string {
+		entry, err := TableToUMLEntry(tbls)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rel, err := ForeignKeyToUMLRelation(tbls)
+		if err != nil {
+			t.Fatal(err)
+		}
+		inh, err := InheritanceToUMLRelation(tbls)
+		if err != nil {
+			t.Fatal(err)
+		}
+		part, err := PartitionToUMLRelation(tbls)
+		if err != nil {
+			t.Fatal(err)
+		}
+		out := string(entry) + string(rel) + string(inh) + string(part)
+		for _, tbl := range tbls {
+			rst, err := TableToRSTTable(tbl)
+			if err != nil {
+				t.Fatal(err)
+			}
+			out += string(rst)
+		}
+		return out
+	}
+	if want, got := render(tbls), render(restored); want != got {
+		t.Errorf("want\n%s\ngot\n%s", want, got)
+	}
+	approval, _ := FindTable(restored, "public", "order_detail_approval")
+	fk := approval.ForeingKeys[0]
+	if fk.SourceTable != approval || fk.TargetTable == nil || fk.Columns[0].TargetColumn == nil {
+		t.Errorf("foreign key not relinked: %+v", fk)
+	}
+}
+
+func TestReadSnapshotVersion(t *testing.T) {
+	_, err := ReadSnapshot(strings.NewReader(`{"version": 99, "schemas": ["public"], "tables": []}`))
+	if err == nil || !strings.Contains(err.Error(), "unsupported snapshot version 99") || !strings.Contains(err.Error(), "re-create") {
+		t.Errorf("want version error got %v", err)
+	}
+}
+
+func TestReadSnapshotV1(t *testing.T) {
+	v1 := `{
+  "Version": 1,
+  "Schemas": ["public"],
+  "Tables": [{
+    "Schema": "public", "Name": "t", "Color": "#FFAAAA", "SelectRoles": ["analyst"],
+    "Comment": {"String": "", "Valid": true},
+    "Columns": [{"Name": "status", "DataType": "mood", "NotNull": true,
+      "DefVal": {"String": "", "Valid": false},
+      "Enum": {"Schema": "public", "Name": "mood", "Values": ["sad", "ok"]}}]
+  }]
+}`
+	s, err := ReadSnapshot(strings.NewReader(v1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Version != SnapshotVersion || len(s.Tables) != 1 || len(s.Enums) != 1 {
+		t.Fatalf("v1 snapshot not upgraded: %+v", s)
+	}
+	tbl := s.Tables[0]
+	if tbl.Color != "" || tbl.SelectRoles != nil {
+		t.Errorf("display state not dropped: %+v", tbl)
+	}
+	if !tbl.Comment.Valid || tbl.Columns[0].DefVal.Valid || tbl.Columns[0].Enum.Values[1] != "ok" {
+		t.Errorf("v1 table not read: %+v", tbl)
+	}
+}
+
+func TestSnapshotEnumsAndEmptyStrings(t *testing.T) {
+	tbls, err := ParseDDL(`CREATE TABLE t (note text DEFAULT '');
+COMMENT ON TABLE t IS '';`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	unused := &Enum{Schema: "public", Name: "mood", Values: []string{"sad", "ok"}}
+	var buf bytes.Buffer
+	if err := WriteSnapshot(&buf, []string{"public"}, tbls, []*Enum{unused}); err != nil {
+		t.Fatal(err)
+	}
+	s, err := ReadSnapshot(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(s.Enums) != 1 || s.Enums[0].Name != "mood" || len(s.Enums[0].Values) != 2 {
+		t.Errorf("unused enum not kept: %+v", s.Enums)
+	}
+	tbl := s.Tables[0]
+	if !tbl.Comment.Valid || tbl.Comment.String != "" {
+		t.Errorf("want empty comment got %+v", tbl.Comment)
+	}
+	if c := tbl.Columns[0]; !c.DefVal.Valid || c.DefVal.String != "''" {
+		t.Errorf("want empty default got %+v", c.DefVal)
+	}
+	if c := tbl.Columns[0]; c.Comment.Valid {
+		t.Errorf("want NULL column comment got %+v", c.Comment)
+	}
+}
+
+func TestWriteSnapshotFormat(t *testing.T) {
+	src, err := ioutil.ReadFile("./example/ddl.sql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tbls, err := ParseDDL(string(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tbls[0].Color = "#FFAAAA"
+	tbls[0].SelectRoles = []string{"analyst"}
+	var buf bytes.Buffer
+	if err := WriteSnapshot(&buf, []string{"public"}, tbls, nil); err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []string{`"version": 2`, `"foreign_keys": [`, `"enums": [`, `"enum": {`} {
+		if !strings.Contains(buf.String(), s) {
+			t.Errorf("%s not found in snapshot", s)
+		}
+	}
+	for _, s := range []string{`"Valid"`, `ForeingKeys`, `Color`, `analyst`} {
+		if strings.Contains(buf.String(), s) {
+			t.Errorf("unexpected %s in snapshot", s)
+		}
+	}
+	if n := strings.Count(buf.String(), `"values"`); n != 1 {
+		t.Errorf("want enum values stored once got %d", n)
+	}
+}
+
+func TestDiffTables(t *testing.T) {
+	src, err := ioutil.ReadFile("./example/ddl.sql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	old, err := ParseDDL(string(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	changed := string(src)
+	for _, r := range [][2]string{
+		{"  , weight numeric not null\n", "  , weight numeric\n  , barcode text not null\n"},
+		{"  , size text not null\n", ""},
+		{"COMMENT ON TABLE customer IS 'Customer Information';", "COMMENT ON TABLE customer IS 'Customers';"},
+		{"  , FOREIGN KEY(sku_id) REFERENCES sku (id)\n", ""},
+		{"'standard', 'express', 'pickup'", "'standard', 'express'"},
+		{"create table vendor_address (", "create table vendor_location ("},
+	} {
+		changed = strings.Replace(changed, r[0], r[1], 1)
+	}
+	new, err := ParseDDL(changed)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	d := DiffTables(old, new)
+	var tbls []string
+	for _, td := range d.Tables {
+		tbls = append(tbls, td.Mark()+td.Name)
+	}
+	expected := []string{"~customer", "~order_detail", "~sku", "-vendor_address", "+vendor_location"}
+	if !reflect.DeepEqual(tbls, expected) {
+		t.Errorf("want %v got %v", expected, tbls)
+	}
+	if len(d.Types) != 1 || d.Types[0].Name != "delivery_method" {
+		t.Errorf("unexpected type diff: %+v", d.Types)
+	}
+
+	text, err := DiffToText(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range []string{
+		"~ table public.customer\n    comment: \"Customer Information\" -> \"Customers\"\n",
+		"    - foreign key order_detail_sku_id_fkey (sku_id) -> public.sku (id)\n",
+		"    - column size TEXT NOT NULL\n",
+		"    ~ column weight\n        nullability: \"NOT NULL\" -> \"NULL\"\n",
+		"    + column barcode TEXT NOT NULL\n",
+		"~ enum public.delivery_method\n    values: \"standard, express, pickup\" -> \"standard, express\"\n",
+	} {
+		if !strings.Contains(string(text), line) {
+			t.Errorf("%q not found in\n%s", line, text)
+		}
+	}
+
+	uml, err := DiffToUML(d, old, new)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range []string{
+		`entity "public.sku" as public.sku #FFFFAA {`,
+		`entity "public.vendor_address" as public.vendor_address #FFCCCC {`,
+		`entity "public.vendor_location" as public.vendor_location #CCFFCC {`,
+		`entity "public.product" as public.product {`,
+	} {
+		if !strings.Contains(string(uml), line) {
+			t.Errorf("%q not found in\n%s", line, uml)
+		}
+	}
+
+	same, err := DiffToText(DiffTables(old, old))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(same) != "no differences\n" {
+		t.Errorf("want no differences got %q", same)
+	}
+}
+
+func TestMermaidDiagram(t *testing.T) {
+	src, err := ioutil.ReadFile("./example/ddl.sql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tbls, err := ParseDDL(string(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tbls = FilterTables(false, tbls, []string{"vendor"})
+	buf, err := MermaidDiagram(FilterPartitions(tbls))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(buf), "erDiagram\n") {
+		t.Errorf("missing erDiagram header in\n%s", buf)
+	}
+	for _, line := range []string{
+		"    \"public.customer\" {\n        BIGINT id PK\n        TEXT name \"Customer Name\"\n",
+		"        BIGINT customer_order_id PK, FK\n",
+		"        BIGINT product_id FK \"UN1\"\n",
+		"        TEXT color \"UN1\"\n",
+		"        unknown order_count\n",
+		"    \"public.customer_order\" }o..|| \"public.customer\" : \"customer_id = id\"\n",
+		"    \"public.order_detail\" }o--|| \"public.customer_order\" : \"customer_order_id = id\"\n",
+		"    \"public.order_detail_approval\" |o--|| \"public.order_detail\" : \"(order_detail_id, customer_order_id) = (id, customer_order_id)\"\n",
+	} {
+		if !strings.Contains(string(buf), line) {
+			t.Errorf("%q not found in\n%s", line, buf)
+		}
+	}
+	if strings.Contains(string(buf), "\"public.vendor\"") {
+		t.Errorf("relationship to filtered table public.vendor in\n%s", buf)
+	}
+}
+
+func TestTableToRSTTableEscape(t *testing.T) {
+	tbls, err := ParseDDL(`CREATE TABLE payment (
+  "Amount" numeric NOT NULL,
+  note text,
+  CONSTRAINT payment_amount_check CHECK ("Amount" > 0)
+);
+CREATE INDEX payment_note_idx ON payment (note) WHERE note <> '"';
+COMMENT ON TABLE payment IS 'the "main" table';
+COMMENT ON COLUMN payment."Amount" IS 'paid "net"';`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf, err := TableToRSTTable(tbls[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []string{
+		"\nthe \"main\" table\n",
+		`"CHECK (""Amount"" > 0)"`,
+		`"note <> '""'"`,
+		`"paid ""net"""`,
+	} {
+		if !strings.Contains(string(buf), s) {
+			t.Errorf("%s not found in\n%s", s, buf)
+		}
+	}
+}
+
+func TestParseDDLEnumArray(t *testing.T) {
+	tbls, err := ParseDDL(`CREATE TYPE status AS ENUM ('open', 'closed');
+CREATE TABLE ticket (history status[]);`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := tbls[0].Columns[0]
+	if c.Enum == nil || c.Enum.Name != "status" || c.TypeName != "status" {
+		t.Errorf("want enum status got %+v", c)
+	}
+	if c.EnumValueList() != "open, closed" {
+		t.Errorf("want open, closed got %s", c.EnumValueList())
+	}
+}
+
+func TestCheckSelectRolesLimit(t *testing.T) {
+	err := CheckSelectRoles(context.Background(), nil, []string{"a", "b", "c", "d"})
+	if err == nil || !strings.Contains(err.Error(), "at most 3 select roles") {
+		t.Errorf("want at most 3 select roles error got %v", err)
+	}
+}
+
+func TestCheckServerVersion(t *testing.T) {
+	if err := checkServerVersion(120000); err != nil {
+		t.Errorf("want no error for 12 got %v", err)
+	}
+	err := checkServerVersion(110012)
+	if err == nil || !strings.Contains(err.Error(), "requires PostgreSQL 12") || !strings.Contains(err.Error(), "110012") {
+		t.Errorf("want version error got %v", err)
+	}
+}
+
+func TestCheckSelectRoles(t *testing.T) {
+	conn, cleanup := testPgSetup(t)
+	defer cleanup()
+
+	if err := CheckSelectRoles(context.Background(), conn, []string{"planter", "public"}); err != nil {
+		t.Fatal(err)
+	}
+	err := CheckSelectRoles(context.Background(), conn, []string{"planter", "no_such_role"})
+	if err == nil || !strings.Contains(err.Error(), "unknown select roles: no_such_role") {
+		t.Errorf("want unknown select roles error got %v", err)
+	}
+}
+
+func TestLoadDDLErrors(t *testing.T) {
+	dir, err := ioutil.TempDir("", "planter")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	files := map[string]string{
+		"001_init.sql":  "CREATE TABLE customer (id bigint PRIMARY KEY);\n",
+		"002_order.sql": "-- orders\n\nCREATE TABLE customer_order (id bigint) PARTITION BY;\n",
+	}
+	for name, src := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(src), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	_, err = LoadDDL(dir, []string{"public"}, "")
+	if err == nil || !strings.Contains(err.Error(), "002_order.sql: line 3: expected partition key") {
+		t.Errorf("want error at 002_order.sql line 3 got %v", err)
+	}
+
+	_, err = ParseDDL("CREATE TABLE (id bigint);\nCREATE TABLE customer_event (id bigint, payload jsonb, created_at timestamptz);\n")
+	if err == nil || strings.Contains(err.Error(), "customer_event") {
+		t.Errorf("want error without the rest of the dump got %v", err)
+	}
+}
+
+func TestParseDDLGrants(t *testing.T) {
+	src := `CREATE TABLE public.customer (id bigint PRIMARY KEY, email text, note text);
+CREATE SEQUENCE public.customer_id_seq;
+ALTER TABLE public.customer OWNER TO app;
+REVOKE ALL ON TABLE public.customer FROM app;
+GRANT SELECT,INSERT ON TABLE public.customer TO app;
+GRANT SELECT ON TABLE public.customer TO PUBLIC;
+GRANT ALL ON TABLE public.customer TO admin WITH GRANT OPTION;
+REVOKE GRANT OPTION FOR TRUNCATE ON public.customer FROM admin;
+GRANT SELECT(email),UPDATE(email, note) ON TABLE public.customer TO support;
+GRANT UPDATE(note) ON TABLE public.customer TO auditor;
+REVOKE UPDATE ON public.customer FROM support;
+GRANT USAGE ON SEQUENCE public.customer_id_seq TO app;
+GRANT USAGE ON SCHEMA public TO PUBLIC;
+ALTER TABLE public.customer DROP COLUMN note;`
+	tbls, err := ParseDDL(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tbl, _ := FindTable(tbls, "public", "customer")
+	var got []string
+	for _, g := range tbl.Grants {
+		got = append(got, g.Column+"|"+g.Grantee+"|"+g.Privileges)
+	}
+	want := []string{
+		"|PUBLIC|SELECT",
+		"|admin|DELETE*, INSERT*, REFERENCES*, SELECT*, TRIGGER*, TRUNCATE, UPDATE*",
+		"|app|INSERT, SELECT",
+		"email|support|SELECT",
+	}
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("want %q got %q", want, got)
+	}
+}
+
+func TestParseDDLEscapeString(t *testing.T) {
+	tbls, err := ParseDDL(`CREATE TABLE customer (id bigint);
+COMMENT ON TABLE customer IS E'first\nsecond \'quoted\' \x41\102\\';`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := "first\nsecond 'quoted' AB\\"; tbls[0].Comment.String != expected {
+		t.Errorf("want %q got %q", expected, tbls[0].Comment.String)
+	}
+}
+
+func TestMermaidKeys(t *testing.T) {
+	code := &Column{Name: "code", IsUnique: true}
+	email := &Column{Name: "email", Comment: sql.NullString{String: `login "email"`, Valid: true}}
+	tbl := &Table{
+		Name:    "account",
+		Columns: []*Column{code, email},
+		UniqueGroups: []*UniqueGroup{
+			&UniqueGroup{Name: "account_tenant_id_email_key", Columns: []string{"tenant_id", "email"}},
+		},
+	}
+	if k := tbl.MermaidKeys(code); k != "UK" {
+		t.Errorf("want UK got %q", k)
+	}
+	if k := tbl.MermaidKeys(email); k != "" {
+		t.Errorf("want no keys got %q", k)
+	}
+	if c := tbl.MermaidComment(email); c != "UN1 login 'email'" {
+		t.Errorf("want UN1 login 'email' got %q", c)
+	}
+}
+
+func TestIsConnStr(t *testing.T) {
+	cases := []struct {
+		s        string
+		expected bool
+	}{
+		{s: "postgres://planter@localhost/planter?sslmode=disable", expected: true},
+		{s: "postgresql://localhost", expected: true},
+		{s: "user=planter dbname=planter sslmode=disable", expected: true},
+		{s: "host=localhost password='a b'", expected: true},
+		{s: "snap/v=2.json", expected: false},
+		{s: "snapshot.json", expected: false},
+		{s: "file:///tmp/snap.json", expected: false},
+	}
+	for _, c := range cases {
+		if got := is_conn_str(c.s); got != c.expected {
+			t.Errorf("%s: want %t got %t", c.s, c.expected, got)
+		}
+	}
 }
@@ -0,0 +1,572 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+
+	"github.com/pkg/errors"
+)
+
+// SnapshotVersion version of the snapshot format written by WriteSnapshot
+const SnapshotVersion = 2
+
+// Snapshot loaded model of schemas, as read by ReadSnapshot
+type Snapshot struct {
+	Version int
+	Schemas []string
+	Tables  []*Table
+	Enums   []*Enum
+}
+
+// snapshotV1 version 1 snapshot, the model structs marshaled as they are
+type snapshotV1 struct {
+	Version int
+	Schemas []string
+	Tables  []*Table
+}
+
+// snapshotFile JSON snapshot format. Types are stored once and referenced by
+// columns, display state like colors and statistics is left out.
+type snapshotFile struct {
+	Version        int                      `json:"version"`
+	Schemas        []string                 `json:"schemas"`
+	Enums          []*snapshotEnum          `json:"enums,omitempty"`
+	Domains        []*snapshotDomain        `json:"domains,omitempty"`
+	CompositeTypes []*snapshotCompositeType `json:"composite_types,omitempty"`
+	Tables         []*snapshotTable         `json:"tables"`
+}
+
+type snapshotTypeRef struct {
+	Schema string `json:"schema"`
+	Name   string `json:"name"`
+}
+
+type snapshotEnum struct {
+	Schema string   `json:"schema"`
+	Name   string   `json:"name"`
+	Values []string `json:"values"`
+}
+
+type snapshotDomain struct {
+	Schema   string   `json:"schema"`
+	Name     string   `json:"name"`
+	BaseType string   `json:"base_type"`
+	NotNull  bool     `json:"not_null,omitempty"`
+	Checks   []string `json:"checks,omitempty"`
+}
+
+type snapshotCompositeType struct {
+	Schema     string                        `json:"schema"`
+	Name       string                        `json:"name"`
+	Attributes []*snapshotCompositeAttribute `json:"attributes"`
+}
+
+type snapshotCompositeAttribute struct {
+	Name     string `json:"name"`
+	DataType string `json:"data_type"`
+}
+
+type snapshotTable struct {
+	Schema           string                 `json:"schema"`
+	Name             string                 `json:"name"`
+	Kind             string                 `json:"kind"`
+	Comment          *string                `json:"comment,omitempty"`
+	AutoGenPk        bool                   `json:"auto_gen_pk,omitempty"`
+	IsPartition      bool                   `json:"is_partition,omitempty"`
+	PartitionKey     *string                `json:"partition_key,omitempty"`
+	RowSecurity      bool                   `json:"row_security,omitempty"`
+	ForceRowSecurity bool                   `json:"force_row_security,omitempty"`
+	Columns          []*snapshotColumn      `json:"columns"`
+	ForeignKeys      []*snapshotForeignKey  `json:"foreign_keys,omitempty"`
+	Partitions       []*snapshotPartition   `json:"partitions,omitempty"`
+	Inherits         []*snapshotInheritance `json:"inherits,omitempty"`
+	Indexes          []*snapshotIndex       `json:"indexes,omitempty"`
+	Constraints      []*snapshotConstraint  `json:"constraints,omitempty"`
+	UniqueGroups     []*snapshotUniqueGroup `json:"unique_groups,omitempty"`
+	Triggers         []*snapshotTrigger     `json:"triggers,omitempty"`
+	Policies         []*snapshotPolicy      `json:"policies,omitempty"`
+	Grants           []*snapshotGrant       `json:"grants,omitempty"`
+}
+
+type snapshotColumn struct {
+	Ordinal       int              `json:"ordinal"`
+	Name          string           `json:"name"`
+	Comment       *string          `json:"comment,omitempty"`
+	DataType      string           `json:"data_type"`
+	DDLType       string           `json:"ddl_type"`
+	NotNull       bool             `json:"not_null,omitempty"`
+	IsPrimaryKey  bool             `json:"is_primary_key,omitempty"`
+	IsUnique      bool             `json:"is_unique,omitempty"`
+	IsInherited   bool             `json:"is_inherited,omitempty"`
+	Default       *string          `json:"default,omitempty"`
+	GeneratedExpr *string          `json:"generated_expr,omitempty"`
+	IdentityKind  string           `json:"identity_kind,omitempty"`
+	SequenceName  *string          `json:"sequence_name,omitempty"`
+	TypeSchema    string           `json:"type_schema,omitempty"`
+	TypeName      string           `json:"type_name,omitempty"`
+	Enum          *snapshotTypeRef `json:"enum,omitempty"`
+	Domain        *snapshotTypeRef `json:"domain,omitempty"`
+	CompositeType *snapshotTypeRef `json:"composite_type,omitempty"`
+}
+
+type snapshotForeignKey struct {
+	Name          string   `json:"name"`
+	TargetSchema  string   `json:"target_schema"`
+	TargetTable   string   `json:"target_table"`
+	SourceColumns []string `json:"source_columns"`
+	TargetColumns []string `json:"target_columns"`
+}
+
+type snapshotPartition struct {
+	Schema string `json:"schema"`
+	Name   string `json:"name"`
+	Bound  string `json:"bound"`
+}
+
+type snapshotInheritance struct {
+	ParentSchema string `json:"parent_schema"`
+	ParentTable  string `json:"parent_table"`
+}
+
+type snapshotIndex struct {
+	Name      string   `json:"name"`
+	Method    string   `json:"method"`
+	IsUnique  bool     `json:"is_unique,omitempty"`
+	IsPrimary bool     `json:"is_primary,omitempty"`
+	Columns   []string `json:"columns"`
+	Predicate *string  `json:"predicate,omitempty"`
+}
+
+type snapshotConstraint struct {
+	Name       string   `json:"name"`
+	Type       string   `json:"type"`
+	Definition string   `json:"definition"`
+	Columns    []string `json:"columns,omitempty"`
+}
+
+type snapshotUniqueGroup struct {
+	Name    string   `json:"name"`
+	Columns []string `json:"columns"`
+}
+
+type snapshotTrigger struct {
+	Name           string   `json:"name"`
+	Timing         string   `json:"timing"`
+	Events         []string `json:"events"`
+	Level          string   `json:"level"`
+	FunctionSchema string   `json:"function_schema"`
+	FunctionName   string   `json:"function_name"`
+	Enabled        bool     `json:"enabled"`
+}
+
+type snapshotPolicy struct {
+	Name       string   `json:"name"`
+	Command    string   `json:"command"`
+	Permissive bool     `json:"permissive"`
+	Roles      []string `json:"roles"`
+	Using      *string  `json:"using,omitempty"`
+	WithCheck  *string  `json:"with_check,omitempty"`
+}
+
+type snapshotGrant struct {
+	Grantee    string `json:"grantee"`
+	Column     string `json:"column,omitempty"`
+	Privileges string `json:"privileges"`
+}
+
+// WriteSnapshot write tables and enums loaded from schemas as a JSON snapshot.
+// Enums used by columns of tbls are written even if not in enums.
+func WriteSnapshot(w io.Writer, schemas []string, tbls []*Table, enums []*Enum) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(toSnapshotFile(schemas, tbls, enums)); err != nil {
+		return errors.Wrap(err, "failed to write snapshot")
+	}
+	return nil
+}
+
+// ReadSnapshot read a JSON snapshot written by WriteSnapshot, upgrading version 1 snapshots
+func ReadSnapshot(r io.Reader) (*Snapshot, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read snapshot")
+	}
+	var head struct {
+		Version int `json:"version"`
+	}
+	if err := json.Unmarshal(data, &head); err != nil {
+		return nil, errors.Wrap(err, "failed to read snapshot")
+	}
+	switch head.Version {
+	case 1:
+		var v1 snapshotV1
+		if err := json.Unmarshal(data, &v1); err != nil {
+			return nil, errors.Wrap(err, "failed to read snapshot")
+		}
+		return upgradeSnapshotV1(&v1), nil
+	case SnapshotVersion:
+		var f snapshotFile
+		if err := json.Unmarshal(data, &f); err != nil {
+			return nil, errors.Wrap(err, "failed to read snapshot")
+		}
+		return fromSnapshotFile(&f), nil
+	}
+	return nil, errors.Errorf("unsupported snapshot version %d, want %d: re-create the snapshot with --snapshot", head.Version, SnapshotVersion)
+}
+
+// upgradeSnapshotV1 drop the display state version 1 snapshots carried
+func upgradeSnapshotV1(v1 *snapshotV1) *Snapshot {
+	for _, tbl := range v1.Tables {
+		tbl.Color = ""
+		tbl.SelectRoles = nil
+		tbl.Stats = nil
+		for _, c := range tbl.Columns {
+			c.Stats = nil
+		}
+	}
+	return &Snapshot{
+		Version: SnapshotVersion,
+		Schemas: v1.Schemas,
+		Tables:  v1.Tables,
+		Enums:   TableEnums(v1.Tables),
+	}
+}
+
+// LoadSnapshot load table definition and enums of schemas from a JSON snapshot file,
+// honoring the skip flags of LoadTableDef
+func LoadSnapshot(path string, schemas []string, skipFlags string) (*Snapshot, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read snapshot")
+	}
+	defer f.Close()
+	s, err := ReadSnapshot(f)
+	if err != nil {
+		return nil, errors.Wrapf(err, "%s", path)
+	}
+	for _, schema := range schemas {
+		if !hasString(s.Schemas, schema) {
+			return nil, errors.Errorf("schema %s not in snapshot %s", schema, path)
+		}
+	}
+	s.Tables = schemaTables(s.Tables, schemas, skipFlags)
+	relinkTables(s.Tables)
+	var enums []*Enum
+	for _, e := range s.Enums {
+		if hasString(schemas, e.Schema) {
+			enums = append(enums, e)
+		}
+	}
+	s.Enums = MergeEnums(enums, TableEnums(s.Tables))
+	return s, nil
+}
+
+// relinkTables restore references between tables and columns that are not serialized
+func relinkTables(tbls []*Table) {
+	var fks []*ForeignKey
+	for _, tbl := range tbls {
+		attachForeignKeys(tbl, tbl.ForeingKeys)
+		fks = append(fks, tbl.ForeingKeys...)
+		resolvePartitions(tbls, tbl.Partitions)
+	}
+	ResolveForeignKeys(tbls, fks)
+	ResolveInheritance(tbls)
+}
+
+// nullStringPtr nullable string as a JSON value that is left out when NULL
+func nullStringPtr(s sql.NullString) *string {
+	if !s.Valid {
+		return nil
+	}
+	return &s.String
+}
+
+func nullString(s *string) sql.NullString {
+	if s == nil {
+		return sql.NullString{}
+	}
+	return sql.NullString{String: *s, Valid: true}
+}
+
+func typeRef(schema, name string) *snapshotTypeRef {
+	return &snapshotTypeRef{Schema: schema, Name: name}
+}
+
+func toSnapshotFile(schemas []string, tbls []*Table, enums []*Enum) *snapshotFile {
+	f := &snapshotFile{
+		Version: SnapshotVersion,
+		Schemas: schemas,
+		Tables:  []*snapshotTable{},
+	}
+	for _, e := range MergeEnums(enums, TableEnums(tbls)) {
+		f.Enums = append(f.Enums, &snapshotEnum{Schema: e.Schema, Name: e.Name, Values: e.Values})
+	}
+	for _, d := range TableDomains(tbls) {
+		f.Domains = append(f.Domains, &snapshotDomain{
+			Schema:   d.Schema,
+			Name:     d.Name,
+			BaseType: d.BaseType,
+			NotNull:  d.NotNull,
+			Checks:   d.Checks,
+		})
+	}
+	for _, ct := range TableCompositeTypes(tbls) {
+		st := &snapshotCompositeType{Schema: ct.Schema, Name: ct.Name}
+		for _, a := range ct.Attributes {
+			st.Attributes = append(st.Attributes, &snapshotCompositeAttribute{Name: a.Name, DataType: a.DataType})
+		}
+		f.CompositeTypes = append(f.CompositeTypes, st)
+	}
+	for _, tbl := range tbls {
+		f.Tables = append(f.Tables, toSnapshotTable(tbl))
+	}
+	return f
+}
+
+func toSnapshotTable(tbl *Table) *snapshotTable {
+	st := &snapshotTable{
+		Schema:           tbl.Schema,
+		Name:             tbl.Name,
+		Kind:             tbl.Kind,
+		Comment:          nullStringPtr(tbl.Comment),
+		AutoGenPk:        tbl.AutoGenPk,
+		IsPartition:      tbl.IsPartition,
+		PartitionKey:     nullStringPtr(tbl.PartitionKey),
+		RowSecurity:      tbl.RowSecurity,
+		ForceRowSecurity: tbl.ForceRowSecurity,
+		Columns:          []*snapshotColumn{},
+	}
+	for _, c := range tbl.Columns {
+		sc := &snapshotColumn{
+			Ordinal:       c.FieldOrdinal,
+			Name:          c.Name,
+			Comment:       nullStringPtr(c.Comment),
+			DataType:      c.DataType,
+			DDLType:       c.DDLType,
+			NotNull:       c.NotNull,
+			IsPrimaryKey:  c.IsPrimaryKey,
+			IsUnique:      c.IsUnique,
+			IsInherited:   c.IsInherited,
+			Default:       nullStringPtr(c.DefVal),
+			GeneratedExpr: nullStringPtr(c.GeneratedExpr),
+			IdentityKind:  c.IdentityKind,
+			SequenceName:  nullStringPtr(c.SequenceName),
+			TypeSchema:    c.TypeSchema,
+			TypeName:      c.TypeName,
+		}
+		if c.Enum != nil {
+			sc.Enum = typeRef(c.Enum.Schema, c.Enum.Name)
+		}
+		if c.Domain != nil {
+			sc.Domain = typeRef(c.Domain.Schema, c.Domain.Name)
+		}
+		if c.Composite != nil {
+			sc.CompositeType = typeRef(c.Composite.Schema, c.Composite.Name)
+		}
+		st.Columns = append(st.Columns, sc)
+	}
+	for _, fk := range tbl.ForeingKeys {
+		st.ForeignKeys = append(st.ForeignKeys, &snapshotForeignKey{
+			Name:          fk.ConstraintName,
+			TargetSchema:  fk.TargetSchemaName,
+			TargetTable:   fk.TargetTableName,
+			SourceColumns: fk.SourceColNames(),
+			TargetColumns: fk.TargetColNames(),
+		})
+	}
+	for _, p := range tbl.Partitions {
+		st.Partitions = append(st.Partitions, &snapshotPartition{Schema: p.Schema, Name: p.Name, Bound: p.Bound})
+	}
+	for _, i := range tbl.Inherits {
+		st.Inherits = append(st.Inherits, &snapshotInheritance{ParentSchema: i.ParentSchemaName, ParentTable: i.ParentTableName})
+	}
+	for _, idx := range tbl.Indexes {
+		st.Indexes = append(st.Indexes, &snapshotIndex{
+			Name:      idx.Name,
+			Method:    idx.Method,
+			IsUnique:  idx.IsUnique,
+			IsPrimary: idx.IsPrimary,
+			Columns:   idx.Columns,
+			Predicate: nullStringPtr(idx.Predicate),
+		})
+	}
+	for _, con := range tbl.Constraints {
+		st.Constraints = append(st.Constraints, &snapshotConstraint{
+			Name:       con.Name,
+			Type:       con.Type,
+			Definition: con.Definition,
+			Columns:    con.Columns,
+		})
+	}
+	for _, g := range tbl.UniqueGroups {
+		st.UniqueGroups = append(st.UniqueGroups, &snapshotUniqueGroup{Name: g.Name, Columns: g.Columns})
+	}
+	for _, tg := range tbl.Triggers {
+		st.Triggers = append(st.Triggers, &snapshotTrigger{
+			Name:           tg.Name,
+			Timing:         tg.Timing,
+			Events:         tg.Events,
+			Level:          tg.Level,
+			FunctionSchema: tg.FunctionSchema,
+			FunctionName:   tg.FunctionName,
+			Enabled:        tg.Enabled,
+		})
+	}
+	for _, pol := range tbl.Policies {
+		st.Policies = append(st.Policies, &snapshotPolicy{
+			Name:       pol.Name,
+			Command:    pol.Command,
+			Permissive: pol.Permissive,
+			Roles:      pol.Roles,
+			Using:      nullStringPtr(pol.Using),
+			WithCheck:  nullStringPtr(pol.WithCheck),
+		})
+	}
+	for _, g := range tbl.Grants {
+		st.Grants = append(st.Grants, &snapshotGrant{Grantee: g.Grantee, Column: g.Column, Privileges: g.Privileges})
+	}
+	return st
+}
+
+func fromSnapshotFile(f *snapshotFile) *Snapshot {
+	s := &Snapshot{Version: f.Version, Schemas: f.Schemas}
+	enums := make(map[snapshotTypeRef]*Enum)
+	for _, se := range f.Enums {
+		e := &Enum{Schema: se.Schema, Name: se.Name, Values: se.Values}
+		enums[*typeRef(e.Schema, e.Name)] = e
+		s.Enums = append(s.Enums, e)
+	}
+	domains := make(map[snapshotTypeRef]*Domain)
+	for _, d := range f.Domains {
+		domains[*typeRef(d.Schema, d.Name)] = &Domain{
+			Schema:   d.Schema,
+			Name:     d.Name,
+			BaseType: d.BaseType,
+			NotNull:  d.NotNull,
+			Checks:   d.Checks,
+		}
+	}
+	composites := make(map[snapshotTypeRef]*CompositeType)
+	for _, st := range f.CompositeTypes {
+		ct := &CompositeType{Schema: st.Schema, Name: st.Name}
+		for _, a := range st.Attributes {
+			ct.Attributes = append(ct.Attributes, &CompositeAttribute{Name: a.Name, DataType: a.DataType})
+		}
+		composites[*typeRef(st.Schema, st.Name)] = ct
+	}
+	for _, st := range f.Tables {
+		tbl := &Table{
+			Schema:           st.Schema,
+			Name:             st.Name,
+			Kind:             st.Kind,
+			Comment:          nullString(st.Comment),
+			AutoGenPk:        st.AutoGenPk,
+			IsPartition:      st.IsPartition,
+			PartitionKey:     nullString(st.PartitionKey),
+			RowSecurity:      st.RowSecurity,
+			ForceRowSecurity: st.ForceRowSecurity,
+		}
+		for _, sc := range st.Columns {
+			c := &Column{
+				FieldOrdinal:  sc.Ordinal,
+				Name:          sc.Name,
+				Comment:       nullString(sc.Comment),
+				DataType:      sc.DataType,
+				DDLType:       sc.DDLType,
+				NotNull:       sc.NotNull,
+				IsPrimaryKey:  sc.IsPrimaryKey,
+				IsUnique:      sc.IsUnique,
+				IsInherited:   sc.IsInherited,
+				DefVal:        nullString(sc.Default),
+				GeneratedExpr: nullString(sc.GeneratedExpr),
+				IdentityKind:  sc.IdentityKind,
+				SequenceName:  nullString(sc.SequenceName),
+				TypeSchema:    sc.TypeSchema,
+				TypeName:      sc.TypeName,
+			}
+			if sc.Enum != nil {
+				c.Enum = enums[*sc.Enum]
+			}
+			if sc.Domain != nil {
+				c.Domain = domains[*sc.Domain]
+			}
+			if sc.CompositeType != nil {
+				c.Composite = composites[*sc.CompositeType]
+			}
+			tbl.Columns = append(tbl.Columns, c)
+		}
+		for _, sfk := range st.ForeignKeys {
+			fk := &ForeignKey{
+				ConstraintName:   sfk.Name,
+				SourceSchemaName: st.Schema,
+				SourceTableName:  st.Name,
+				TargetSchemaName: sfk.TargetSchema,
+				TargetTableName:  sfk.TargetTable,
+			}
+			for i := range sfk.SourceColumns {
+				fc := &ForeignKeyColumn{SourceColName: sfk.SourceColumns[i]}
+				if i < len(sfk.TargetColumns) {
+					fc.TargetColName = sfk.TargetColumns[i]
+				}
+				fk.Columns = append(fk.Columns, fc)
+			}
+			tbl.ForeingKeys = append(tbl.ForeingKeys, fk)
+		}
+		for _, p := range st.Partitions {
+			tbl.Partitions = append(tbl.Partitions, &Partition{Schema: p.Schema, Name: p.Name, Bound: p.Bound})
+		}
+		for _, i := range st.Inherits {
+			tbl.Inherits = append(tbl.Inherits, &Inheritance{ParentSchemaName: i.ParentSchema, ParentTableName: i.ParentTable})
+		}
+		for _, idx := range st.Indexes {
+			tbl.Indexes = append(tbl.Indexes, &Index{
+				Name:      idx.Name,
+				Method:    idx.Method,
+				IsUnique:  idx.IsUnique,
+				IsPrimary: idx.IsPrimary,
+				Columns:   idx.Columns,
+				Predicate: nullString(idx.Predicate),
+			})
+		}
+		for _, con := range st.Constraints {
+			tbl.Constraints = append(tbl.Constraints, &Constraint{
+				Name:       con.Name,
+				Type:       con.Type,
+				Definition: con.Definition,
+				Columns:    con.Columns,
+			})
+		}
+		for _, g := range st.UniqueGroups {
+			tbl.UniqueGroups = append(tbl.UniqueGroups, &UniqueGroup{Name: g.Name, Columns: g.Columns})
+		}
+		for _, tg := range st.Triggers {
+			tbl.Triggers = append(tbl.Triggers, &Trigger{
+				Name:           tg.Name,
+				Timing:         tg.Timing,
+				Events:         tg.Events,
+				Level:          tg.Level,
+				FunctionSchema: tg.FunctionSchema,
+				FunctionName:   tg.FunctionName,
+				Enabled:        tg.Enabled,
+			})
+		}
+		for _, pol := range st.Policies {
+			tbl.Policies = append(tbl.Policies, &Policy{
+				Name:       pol.Name,
+				Command:    pol.Command,
+				Permissive: pol.Permissive,
+				Roles:      pol.Roles,
+				Using:      nullString(pol.Using),
+				WithCheck:  nullString(pol.WithCheck),
+			})
+		}
+		for _, g := range st.Grants {
+			tbl.Grants = append(tbl.Grants, &Grant{Grantee: g.Grantee, Column: g.Column, Privileges: g.Privileges})
+		}
+		s.Tables = append(s.Tables, tbl)
+	}
+	return s
+}
@@ -2,64 +2,410 @@ package main
 
 const columDefSQL = `
 SELECT
+    n.nspname AS schema_name,
+    c.relname AS table_name,
     a.attnum AS field_ordinal,
     a.attname AS column_name,
     pd.description AS description,
     replace(UPPER(format_type(a.atttypid, a.atttypmod)), 'TIMESTAMP WITH TIME ZONE', 'TIMESTAMPTZ') AS data_type,
+    format_type(a.atttypid, a.atttypmod) AS ddl_type,
     a.attnotnull AS not_null,
     COALESCE(ct.contype = 'p', false) AS  is_primary_key,
-    COALESCE(ct2.contype = 'u', false) AS  is_unique,
-    replace(translate(pg_get_expr(adbin, adrelid), '()', ''), '::timestamp with time zone', '') AS def_val
+    EXISTS (
+      SELECT 1
+      FROM pg_index ui
+      WHERE ui.indrelid = c.oid
+      AND ui.indisunique
+      AND NOT ui.indisprimary
+      AND ui.indnkeyatts = 1
+      AND ui.indkey[0] = a.attnum
+      AND ui.indexprs IS NULL
+      AND ui.indpred IS NULL
+    ) AS is_unique,
+    NOT a.attislocal AS is_inherited,
+    CASE WHEN a.attgenerated = '' THEN pg_get_expr(ad.adbin, ad.adrelid) END AS def_val,
+    CASE WHEN a.attgenerated <> '' THEN pg_get_expr(ad.adbin, ad.adrelid) END AS generated_expr,
+    CASE a.attidentity WHEN 'a' THEN 'ALWAYS' WHEN 'd' THEN 'BY DEFAULT' ELSE '' END AS identity_kind,
+    pg_get_serial_sequence(quote_ident(n.nspname) || '.' || quote_ident(c.relname), a.attname) AS sequence_name,
+    tn.nspname AS type_schema,
+    t.typname AS type_name,
+    t.typtype AS type_type,
+    ARRAY(SELECT e.enumlabel FROM pg_enum e WHERE e.enumtypid = t.oid ORDER BY e.enumsortorder) AS enum_values,
+    CASE WHEN t.typtype = 'd' THEN UPPER(format_type(t.typbasetype, t.typtypmod)) END AS domain_base_type,
+    t.typnotnull AS domain_not_null,
+    ARRAY(
+      SELECT pg_get_constraintdef(dc.oid, true)
+      FROM pg_constraint dc
+      WHERE dc.contypid = t.oid AND dc.contype = 'c'
+      ORDER BY dc.conname
+    ) AS domain_checks,
+    ARRAY(
+      SELECT ta.attname
+      FROM pg_attribute ta
+      WHERE ta.attrelid = t.typrelid AND ta.attnum > 0 AND NOT ta.attisdropped
+      ORDER BY ta.attnum
+    ) AS composite_attr_names,
+    ARRAY(
+      SELECT UPPER(format_type(ta.atttypid, ta.atttypmod))
+      FROM pg_attribute ta
+      WHERE ta.attrelid = t.typrelid AND ta.attnum > 0 AND NOT ta.attisdropped
+      ORDER BY ta.attnum
+    ) AS composite_attr_types
 FROM pg_attribute a
 JOIN ONLY pg_class c ON c.oid = a.attrelid
 JOIN ONLY pg_namespace n ON n.oid = c.relnamespace
+JOIN pg_type at ON at.oid = a.atttypid
+JOIN pg_type t ON t.oid = CASE WHEN at.typcategory = 'A' AND at.typelem <> 0 THEN at.typelem ELSE at.oid END
+JOIN pg_namespace tn ON tn.oid = t.typnamespace
 LEFT JOIN pg_constraint ct ON ct.conrelid = c.oid AND a.attnum = ANY(ct.conkey) AND ct.contype IN ('p' )
-LEFT JOIN pg_constraint ct2 ON ct2.conrelid = c.oid AND a.attnum = ANY(ct2.conkey) AND ct2.contype IN ('u')
 LEFT JOIN pg_attrdef ad ON ad.adrelid = c.oid AND ad.adnum = a.attnum
 LEFT JOIN pg_description pd ON pd.objoid = a.attrelid AND pd.objsubid = a.attnum
 WHERE a.attisdropped = false
-AND n.nspname = $1
-AND c.relname = $2
+AND n.nspname = ANY($1::name[])
+AND ($2::name[] IS NULL OR c.relname = ANY($2::name[]))
+AND c.relkind IN ('r', 'v', 'm', 'p')
 AND a.attnum > 0
-ORDER BY a.attnum
+ORDER BY n.nspname, c.relname, a.attnum
 `
 
 const tableDefSQL = `
 SELECT
+  n.nspname AS schema_name,
   c.relname AS table_name,
+  c.relkind AS kind,
+  c.relispartition AS is_partition,
+  pg_get_partkeydef(c.oid) AS partition_key,
+  c.relrowsecurity AS row_security,
+  c.relforcerowsecurity AS force_row_security,
   pd.description AS description
 FROM pg_class c
 JOIN ONLY pg_namespace n
 ON n.oid = c.relnamespace
 LEFT JOIN pg_description pd ON pd.objoid = c.oid AND pd.objsubid = 0
-WHERE n.nspname = $1
-AND c.relkind = 'r'
-ORDER BY c.relname
+WHERE n.nspname = ANY($1::name[])
+AND c.relkind IN ('r', 'v', 'm', 'p')
+ORDER BY n.nspname, c.relname
 `
 
 const fkDefSQL = `
 select
- DISTINCT cl.relname as "parent_table"
+  ns.nspname as "conn_schema"
+  , cl.relname as "child_table"
   , con.conname
-  , con.nspname "conn_schema"
-  , ns.nspname "parent_schema"
-from (
-  select
-    unnest(con1.conkey) as "parent"
-    , unnest(con1.confkey) as "child"
-    , con1.confrelid
-    , con1.conrelid
-    , con1.conname
-    , ns.nspname
-  from pg_class cl
-  join pg_namespace ns on cl.relnamespace = ns.oid
-  join pg_constraint con1 on con1.conrelid = cl.oid
-  where ns.nspname = $1
-  and cl.relname = $2
-  and con1.contype = 'f'
-) con
-join pg_attribute att on att.attrelid = con.confrelid and att.attnum = con.child
-join pg_class cl on cl.oid = con.confrelid
-join pg_namespace ns on cl.relnamespace = ns.oid
-order by con.conname
+  , fns.nspname as "parent_schema"
+  , fcl.relname as "parent_table"
+  , att.attname as "child_column"
+  , fatt.attname as "parent_column"
+from pg_constraint con
+join pg_class cl on cl.oid = con.conrelid
+join pg_namespace ns on ns.oid = cl.relnamespace
+join pg_class fcl on fcl.oid = con.confrelid
+join pg_namespace fns on fns.oid = fcl.relnamespace
+cross join lateral unnest(con.conkey, con.confkey) with ordinality as k(conkey, confkey, ord)
+join pg_attribute att on att.attrelid = con.conrelid and att.attnum = k.conkey
+join pg_attribute fatt on fatt.attrelid = con.confrelid and fatt.attnum = k.confkey
+where ns.nspname = any($1::name[])
+and ($2::name[] is null or cl.relname = any($2::name[]))
+and con.contype = 'f'
+order by ns.nspname, cl.relname, con.conname, k.ord
+`
+
+const partitionDefSQL = `
+SELECT
+  pn.nspname AS parent_schema,
+  p.relname AS parent_name,
+  cn.nspname AS partition_schema,
+  c.relname AS partition_name,
+  pg_get_expr(c.relpartbound, c.oid) AS partition_bound
+FROM pg_inherits i
+JOIN pg_class c ON c.oid = i.inhrelid
+JOIN pg_namespace cn ON cn.oid = c.relnamespace
+JOIN pg_class p ON p.oid = i.inhparent
+JOIN pg_namespace pn ON pn.oid = p.relnamespace
+WHERE pn.nspname = ANY($1::name[])
+AND ($2::name[] IS NULL OR p.relname = ANY($2::name[]))
+AND c.relispartition
+ORDER BY pn.nspname, p.relname, c.relname
+`
+
+const inheritanceDefSQL = `
+SELECT
+  cn.nspname AS schema_name,
+  c.relname AS table_name,
+  pn.nspname AS parent_schema,
+  p.relname AS parent_name
+FROM pg_inherits i
+JOIN pg_class c ON c.oid = i.inhrelid
+JOIN pg_namespace cn ON cn.oid = c.relnamespace
+JOIN pg_class p ON p.oid = i.inhparent
+JOIN pg_namespace pn ON pn.oid = p.relnamespace
+WHERE cn.nspname = ANY($1::name[])
+AND ($2::name[] IS NULL OR c.relname = ANY($2::name[]))
+AND NOT c.relispartition
+ORDER BY cn.nspname, c.relname, i.inhseqno
+`
+
+const indexDefSQL = `
+SELECT
+  n.nspname AS schema_name,
+  c.relname AS table_name,
+  ic.relname AS index_name,
+  am.amname AS method,
+  i.indisunique AS is_unique,
+  i.indisprimary AS is_primary,
+  ARRAY(
+    SELECT pg_get_indexdef(i.indexrelid, k, true)
+    FROM generate_series(1, i.indnkeyatts) AS k
+    ORDER BY k
+  ) AS columns,
+  pg_get_expr(i.indpred, i.indrelid, true) AS predicate
+FROM pg_index i
+JOIN pg_class c ON c.oid = i.indrelid
+JOIN pg_namespace n ON n.oid = c.relnamespace
+JOIN pg_class ic ON ic.oid = i.indexrelid
+JOIN pg_am am ON am.oid = ic.relam
+WHERE n.nspname = ANY($1::name[])
+AND ($2::name[] IS NULL OR c.relname = ANY($2::name[]))
+ORDER BY n.nspname, c.relname, ic.relname
+`
+
+const uniqueDefSQL = `
+SELECT
+  n.nspname AS schema_name,
+  c.relname AS table_name,
+  ic.relname AS index_name,
+  ARRAY(
+    SELECT a.attname
+    FROM unnest(i.indkey::int2[]) WITH ORDINALITY AS k(attnum, ord)
+    JOIN pg_attribute a ON a.attrelid = i.indrelid AND a.attnum = k.attnum
+    WHERE k.ord <= i.indnkeyatts
+    ORDER BY k.ord
+  ) AS columns
+FROM pg_index i
+JOIN pg_class c ON c.oid = i.indrelid
+JOIN pg_namespace n ON n.oid = c.relnamespace
+JOIN pg_class ic ON ic.oid = i.indexrelid
+WHERE n.nspname = ANY($1::name[])
+AND ($2::name[] IS NULL OR c.relname = ANY($2::name[]))
+AND i.indisunique
+AND NOT i.indisprimary
+AND i.indnkeyatts > 1
+AND i.indexprs IS NULL
+AND i.indpred IS NULL
+ORDER BY n.nspname, c.relname, ic.relname
+`
+
+const constraintDefSQL = `
+SELECT
+  n.nspname AS schema_name,
+  c.relname AS table_name,
+  ct.conname AS constraint_name,
+  ct.contype AS constraint_type,
+  pg_get_constraintdef(ct.oid, true) AS definition,
+  ARRAY(
+    SELECT a.attname
+    FROM unnest(ct.conkey) WITH ORDINALITY AS k(attnum, ord)
+    JOIN pg_attribute a ON a.attrelid = ct.conrelid AND a.attnum = k.attnum
+    ORDER BY k.ord
+  ) AS columns
+FROM pg_constraint ct
+JOIN pg_class c ON c.oid = ct.conrelid
+JOIN pg_namespace n ON n.oid = c.relnamespace
+WHERE n.nspname = ANY($1::name[])
+AND ($2::name[] IS NULL OR c.relname = ANY($2::name[]))
+AND ct.contype IN ('c', 'x')
+ORDER BY n.nspname, c.relname, ct.conname
+`
+
+const triggerDefSQL = `
+SELECT
+  n.nspname AS schema_name,
+  c.relname AS table_name,
+  tg.tgname AS trigger_name,
+  CASE
+    WHEN tg.tgtype & 2 <> 0 THEN 'BEFORE'
+    WHEN tg.tgtype & 64 <> 0 THEN 'INSTEAD OF'
+    ELSE 'AFTER'
+  END AS timing,
+  ARRAY_REMOVE(ARRAY[
+    CASE WHEN tg.tgtype & 4 <> 0 THEN 'INSERT' END,
+    CASE WHEN tg.tgtype & 16 <> 0 THEN 'UPDATE' END,
+    CASE WHEN tg.tgtype & 8 <> 0 THEN 'DELETE' END,
+    CASE WHEN tg.tgtype & 32 <> 0 THEN 'TRUNCATE' END
+  ], NULL) AS events,
+  CASE WHEN tg.tgtype & 1 <> 0 THEN 'ROW' ELSE 'STATEMENT' END AS level,
+  pn.nspname AS function_schema,
+  p.proname AS function_name,
+  tg.tgenabled <> 'D' AS enabled
+FROM pg_trigger tg
+JOIN pg_class c ON c.oid = tg.tgrelid
+JOIN pg_namespace n ON n.oid = c.relnamespace
+JOIN pg_proc p ON p.oid = tg.tgfoid
+JOIN pg_namespace pn ON pn.oid = p.pronamespace
+WHERE n.nspname = ANY($1::name[])
+AND ($2::name[] IS NULL OR c.relname = ANY($2::name[]))
+AND NOT tg.tgisinternal
+%s
+ORDER BY n.nspname, c.relname, tg.tgname
+`
+
+// triggerParentFilterSQL skip row triggers cloned from a partitioned table, which
+// are not internal since Postgres 13
+const triggerParentFilterSQL = `AND tg.tgparentid = 0`
+
+const serverVersionSQL = `SELECT current_setting('server_version_num')::int`
+
+const functionDefSQL = `
+SELECT
+  p.proname AS function_name,
+  CASE p.prokind WHEN 'p' THEN 'procedure' ELSE 'function' END AS kind,
+  pg_get_function_arguments(p.oid) AS arguments,
+  pg_get_function_result(p.oid) AS result,
+  l.lanname AS language,
+  CASE p.provolatile WHEN 'i' THEN 'immutable' WHEN 's' THEN 'stable' ELSE 'volatile' END AS volatility,
+  pd.description AS description
+FROM pg_proc p
+JOIN pg_namespace n ON n.oid = p.pronamespace
+JOIN pg_language l ON l.oid = p.prolang
+LEFT JOIN pg_description pd ON pd.objoid = p.oid AND pd.classoid = 'pg_proc'::regclass AND pd.objsubid = 0
+WHERE n.nspname = $1
+AND p.prokind IN ('f', 'p')
+AND NOT EXISTS (
+  SELECT 1 FROM pg_depend d
+  WHERE d.classid = 'pg_proc'::regclass AND d.objid = p.oid AND d.deptype = 'e'
+)
+ORDER BY p.proname, pg_get_function_arguments(p.oid)
+`
+
+const enumDefSQL = `
+SELECT
+  t.typname AS enum_name,
+  ARRAY(SELECT e.enumlabel FROM pg_enum e WHERE e.enumtypid = t.oid ORDER BY e.enumsortorder) AS enum_values
+FROM pg_type t
+JOIN pg_namespace n ON n.oid = t.typnamespace
+WHERE n.nspname = $1
+AND t.typtype = 'e'
+AND NOT EXISTS (
+  SELECT 1 FROM pg_depend d
+  WHERE d.classid = 'pg_type'::regclass AND d.objid = t.oid AND d.deptype = 'e'
+)
+ORDER BY t.typname
+`
+
+const policyDefSQL = `
+SELECT
+  n.nspname AS schema_name,
+  c.relname AS table_name,
+  pol.polname AS policy_name,
+  CASE pol.polcmd
+    WHEN 'r' THEN 'SELECT'
+    WHEN 'a' THEN 'INSERT'
+    WHEN 'w' THEN 'UPDATE'
+    WHEN 'd' THEN 'DELETE'
+    ELSE 'ALL'
+  END AS command,
+  pol.polpermissive AS permissive,
+  ARRAY(
+    SELECT CASE WHEN r = 0 THEN 'PUBLIC' ELSE pg_get_userbyid(r) END
+    FROM unnest(pol.polroles) AS r
+    ORDER BY 1
+  ) AS roles,
+  pg_get_expr(pol.polqual, pol.polrelid, true) AS using_expr,
+  pg_get_expr(pol.polwithcheck, pol.polrelid, true) AS with_check_expr
+FROM pg_policy pol
+JOIN pg_class c ON c.oid = pol.polrelid
+JOIN pg_namespace n ON n.oid = c.relnamespace
+WHERE n.nspname = ANY($1::name[])
+AND ($2::name[] IS NULL OR c.relname = ANY($2::name[]))
+ORDER BY n.nspname, c.relname, pol.polname
+`
+
+const grantDefSQL = `
+SELECT
+  g.schema_name,
+  g.table_name,
+  g.column_name,
+  CASE WHEN g.grantee = 0 THEN 'PUBLIC' ELSE pg_get_userbyid(g.grantee) END AS grantee,
+  string_agg(g.privilege_type || CASE WHEN g.is_grantable THEN '*' ELSE '' END, ', ' ORDER BY g.privilege_type) AS privileges
+FROM (
+  SELECT n.nspname AS schema_name, c.relname AS table_name, '' AS column_name, acl.grantee, acl.privilege_type, acl.is_grantable
+  FROM pg_class c
+  JOIN pg_namespace n ON n.oid = c.relnamespace
+  CROSS JOIN LATERAL aclexplode(COALESCE(c.relacl, acldefault('r', c.relowner))) AS acl
+  WHERE n.nspname = ANY($1::name[])
+  AND ($2::name[] IS NULL OR c.relname = ANY($2::name[]))
+  AND c.relkind IN ('r', 'v', 'm', 'p')
+  UNION ALL
+  SELECT n.nspname, c.relname, a.attname, acl.grantee, acl.privilege_type, acl.is_grantable
+  FROM pg_attribute a
+  JOIN pg_class c ON c.oid = a.attrelid
+  JOIN pg_namespace n ON n.oid = c.relnamespace
+  CROSS JOIN LATERAL aclexplode(a.attacl) AS acl
+  WHERE n.nspname = ANY($1::name[])
+  AND ($2::name[] IS NULL OR c.relname = ANY($2::name[]))
+  AND c.relkind IN ('r', 'v', 'm', 'p')
+  AND a.attnum > 0
+  AND NOT a.attisdropped
+) g
+GROUP BY g.schema_name, g.table_name, g.column_name, g.grantee
+ORDER BY g.schema_name, g.table_name, g.column_name, 4
+`
+
+const unknownRoleSQL = `
+SELECT r
+FROM unnest($1::text[]) AS r
+WHERE lower(r) <> 'public'
+AND NOT EXISTS (SELECT 1 FROM pg_roles WHERE rolname = r)
+ORDER BY r
+`
+
+const selectRoleDefSQL = `
+SELECT
+  c.relname AS table_name,
+  ARRAY(
+    SELECT r
+    FROM unnest($2::text[]) AS r
+    WHERE has_table_privilege(r, c.oid, 'SELECT')
+    ORDER BY r
+  ) AS roles
+FROM pg_class c
+JOIN pg_namespace n ON n.oid = c.relnamespace
+WHERE n.nspname = $1
+AND c.relkind IN ('r', 'v', 'm', 'p')
+ORDER BY c.relname
+`
+
+const tableStatsSQL = `
+SELECT
+  c.relname AS table_name,
+  CASE WHEN c.relkind = 'p' THEN (
+    SELECT COALESCE(sum(GREATEST(pc.reltuples, 0)), 0)::bigint
+    FROM pg_partition_tree(c.oid) pt
+    JOIN pg_class pc ON pc.oid = pt.relid
+    WHERE pt.isleaf
+  ) ELSE GREATEST(c.reltuples, 0)::bigint END AS row_estimate,
+  CASE WHEN c.relkind = 'p' THEN (
+    SELECT COALESCE(sum(pg_total_relation_size(pt.relid)), 0)::bigint
+    FROM pg_partition_tree(c.oid) pt
+  ) ELSE pg_total_relation_size(c.oid) END AS total_bytes,
+  GREATEST(s.last_vacuum, s.last_autovacuum) AS last_vacuum,
+  GREATEST(s.last_analyze, s.last_autoanalyze) AS last_analyze
+FROM pg_class c
+JOIN pg_namespace n ON n.oid = c.relnamespace
+LEFT JOIN pg_stat_all_tables s ON s.relid = c.oid
+WHERE n.nspname = $1
+AND c.relkind IN ('r', 'm', 'p')
+ORDER BY c.relname
+`
+
+const columnStatsSQL = `
+SELECT DISTINCT ON (s.tablename, s.attname)
+  s.tablename AS table_name,
+  s.attname AS column_name,
+  s.null_frac,
+  s.n_distinct,
+  CASE WHEN $2 THEN s.most_common_vals::text::text[] END AS most_common_vals
+FROM pg_stats s
+WHERE s.schemaname = $1
+ORDER BY s.tablename, s.attname, s.inherited
 `
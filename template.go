@@ -1,57 +1,292 @@
 package main
 
 const entryTmpl = `
-entity "{{ .Name }}" {
+entity "{{ .FullName }}" as {{ .Alias }} {{- if .IsView }} <<view>>{{- else if .IsMaterializedView }} <<materialized view>>{{- else if .IsPartitioned }} <<partitioned>>{{- end }} {{- if .Color }} {{ .Color }}{{- end }} {
 {{- if .Comment.Valid }}
   {{ .Comment.String }}
   ..
 {{- end }}
+{{- if .IsPartitioned }}
+  partition by {{ .PartitionKey.String }}, {{ len .Partitions }} partitions
+  ..
+{{- end }}
+{{- with .Stats }}
+  rows: {{ .RowEstimate }}, size: {{ .TotalSize }}
+  vacuum: {{ .LastVacuumAt }}, analyze: {{ .LastAnalyzeAt }}
+  ..
+{{- end }}
 {{- range .Columns }}
   {{- if .IsPrimaryKey }}
-  + {{ .Name }} [PK]{{- if .Comment.Valid }} : {{ .Comment.String }}{{- end }}
+  + {{ .Name }} [PK] {{- if .IsForeignKey }} [FK]{{- end }} {{- if .Comment.Valid }} : {{ .Comment.String }}{{- end }}
   {{- end }}
 {{- end }}
   --
 {{- range .Columns }}
   {{- if not .IsPrimaryKey }}
-  {{ .Name }} {{- if .Comment.Valid }} : {{ .Comment.String }}{{- end }}
+  {{ .Name }} {{- if .IsForeignKey }} [FK]{{- end }} {{- if .Comment.Valid }} : {{ .Comment.String }}{{- end }}
   {{- end }}
 {{- end }}
 }
+{{- if .Constraints }}
+note bottom of {{ .Alias }}
+{{- range .Constraints }}
+  {{ .Name }}: {{ .Definition }}
+{{- end }}
+end note
+{{- end }}
 `
 
 const relationTmpl = `
-{{ .SourceTableName }} "0..N" -- "1" {{ .TargetTableName }}
+{{ .SourceAlias }} "{{ .SourceCardinality }}" -- "{{ .TargetCardinality }}" {{ .TargetAlias }} {{- if .Columns }} : {{ .ColumnMapping }}{{- end }}
+`
+
+const mermaidEntryTmpl = `
+{{- if .Columns }}
+    "{{ .FullName }}" {
+{{- range .Columns }}
+        {{ .MermaidType }} {{ .MermaidName }} {{- with $.MermaidKeys . }} {{ . }}{{- end }} {{- with $.MermaidComment . }} "{{ . }}"{{- end }}
+{{- end }}
+    }
+{{- else }}
+    "{{ .FullName }}"
+{{- end }}
+`
+
+const mermaidRelationTmpl = `    "{{ .SourceSchemaName }}.{{ .SourceTableName }}" {{ .MermaidRelationship }} "{{ .TargetSchemaName }}.{{ .TargetTableName }}" : "{{ .ColumnMapping }}"
+`
+
+const enumTmpl = `
+enum "{{ .Name }}" as {{ .Alias }} {
+{{- range .Values }}
+  {{ . }}
+{{- end }}
+}
+`
+
+const typeTmpl = `
+{{- define "domain" }}
+class "{{ .Name }}" as {{ .Alias }} << (D,#DDEEFF) domain >> {
+  {{ .BaseType }} {{- if .NotNull }} NN{{- end }}
+{{- range .Checks }}
+  {field} {{ . }}
+{{- end }}
+}
+{{ end }}
+{{- define "composite" }}
+class "{{ .Name }}" as {{ .Alias }} << (C,#DDEEFF) composite >> {
+{{- range .Attributes }}
+  {{ .Name }}: {{ .DataType }}
+{{- end }}
+}
+{{ end }}`
+
+const typeRelationTmpl = `
+{{ .Table.Alias }} ..> {{ .Column.UserType }} : {{ .Column.Name }}
+`
+
+const triggerFunctionTmpl = `
+{{- define "component" }}
+component "{{ .Function }}()" as {{ .FunctionAlias }}
+{{ end }}
+{{- define "relation" }}
+{{ .FunctionAlias }} ..> {{ .Table.Alias }} : {{ .Name }}
+{{ end }}`
+
+const partitionTmpl = `
+{{ .Parent.Alias }} *-- {{ .Alias }} : {{ .Bound }}
+`
+
+const inheritanceTmpl = `
+{{ .ParentAlias }} <|-- {{ .Child.Alias }}
 `
 
 const tableTmpl = `@startuml
 !ifndef ERD_INCL
 !include ../erd.iuml
 !endif
-table({{ .Name }}) {
+{{ if .IsView }}view{{ else if .IsMaterializedView }}mview{{ else if .IsPartitioned }}ptable{{ else }}table{{ end }}("{{ .Name }}" as {{ .Alias }}) {{- if .Color }} {{ .Color }}{{- end }} {
+{{- with .Stats }}
+  {field} rows: {{ .RowEstimate }}, size: {{ .TotalSize }}
+  {field} vacuum: {{ .LastVacuumAt }}, analyze: {{ .LastAnalyzeAt }}
+  ..
+{{- end }}
 {{- range .Columns }}
   {{- if .IsPrimaryKey }}
-  pk({{ .Name }}): {{ .DataType }} {{- if .NotNull }} NN{{- end }}
+  pk({{ .Name }}): {{ .DataType }} {{- if .NotNull }} NN{{- end }} {{- with $.UniqueMarkers .Name }} {{ . }}{{- end }} {{- if .IsForeignKey }} FK{{- end }} {{- if .IsIdentity }} ID{{- else if .IsSerial }} SQ{{- end }}
   {{- else }}
-  {{ .Name }}{{- if .DefVal.Valid }} = {{ .DefVal.String }} {{- end }}: {{ .DataType }} {{- if .NotNull }} NN{{- end }} {{- if .IsUnique }} UN{{- end }}
+  {{ if or .DefVal.Valid .IsGenerated }}{field} {{ end }}{{ .Name }}{{- if .DefVal.Valid }} = {{ .DefVal.String }} {{- else if .IsGenerated }} = {{ .GeneratedExpr.String }} {{- end }}: {{ .DataType }} {{- if .NotNull }} NN{{- end }} {{- if .IsUnique }} UN{{- end }} {{- with $.UniqueMarkers .Name }} {{ . }}{{- end }} {{- if .IsForeignKey }} FK{{- end }} {{- if .IsIdentity }} ID{{- else if .IsSerial }} SQ{{- end }} {{- if .IsGenerated }} GEN{{- end }}
+  {{- end }}
+{{- end }}
+{{- if .IsPartitioned }}
+  ..
+  {field} partition by {{ .PartitionKey.String }}
+  {{ len .Partitions }} partitions
+{{- end }}
+{{- if .Indexes }}
+  __ indexes __
+  {{- range .Indexes }}
+  {field} {{ .Name }}: {{ .Method }} ({{ .ColumnList }}) {{- if .IsUnique }} UN{{- end }} {{- if .Predicate.Valid }} WHERE {{ .Predicate.String }}{{- end }}
+  {{- end }}
+{{- end }}
+{{- if .Triggers }}
+  __ triggers __
+  {{- range .Triggers }}
+  {field} {{ .Name }}: {{ .Timing }} {{ .EventList }} FOR EACH {{ .Level }} EXECUTE {{ .Function }}() {{- if not .Enabled }} DISABLED{{- end }}
   {{- end }}
 {{- end }}
 }
+{{- if .Constraints }}
+note bottom of {{ .Alias }}
+{{- range .Constraints }}
+  {{ .Name }}: {{ .Definition }}
+{{- end }}
+end note
+{{- end }}
 @enduml`
 
+const rstTypeTmpl = `
+.. _tab-sql-{{ .Schema }}_types:
+
+types
+^^^^^^^
+{{ if .Domains }}
+.. csv-table:: domains
+   :header: domain,base type,not null,checks
+{{ range .Domains }}
+   "{{ .Name | csv }}", "{{ .BaseType | csv }}", "{{ if .NotNull }}yes{{ end }}", "{{ range $i, $c := .Checks }}{{ if $i }}, {{ end }}{{ $c | csv }}{{ end }}"
+{{- end }}
+{{ end }}
+{{- if .Composites }}
+.. csv-table:: composite types
+   :header: type,attributes
+{{ range .Composites }}
+   "{{ .Name | csv }}", "{{ .AttributeList | csv }}"
+{{- end }}
+{{ end }}`
+
+const rstFunctionTmpl = `
+.. _tab-sql-{{ .Schema }}_functions:
+
+functions
+^^^^^^^
+
+.. tabularcolumns:: |p{4cm}|p{2cm}|p{2cm}|p{2cm}|p{2cm}|p{4cm}|
+
+.. csv-table:: {{ .Schema }} functions
+   :header: function,kind,returns,language,volatility,description
+{{ range .Functions }}
+   "{{ .Signature | csv }}", "{{ .Kind }}", "{{ .Result.String | csv }}", "{{ .Language }}", "{{ .Volatility }}", "{{- if .Comment.Valid }}{{ .Comment.String | csv }} {{- else }}TODO_ADD_COMMENT{{- end }}"
+{{- end }}
+`
+
 const rstTableTmpl = `
 .. _tab-sql-{{ .Schema }}_{{ .Name }}:
 
 {{ .Name }}
 ^^^^^^^
 
-{{ if .Comment.Valid }}{{ .Comment.String }} {{- else }}TODO_ADD_COMMENT{{- end }}
+{{ if or .IsView .IsMaterializedView .IsPartitioned }}*{{ .KindName }}*
 
-.. tabularcolumns:: |p{3cm}|p{3cm}|p{8cm}|
+{{ end -}}
+{{ if .Comment.Valid }}{{ .Comment.String }} {{- else }}TODO_ADD_COMMENT{{- end }}
+{{ if .Inherits }}
+Inherits from {{ .InheritsNames }}.
+{{ end }}
+{{- with .Stats }}
+:rows: {{ .RowEstimate }} (estimate)
+:size: {{ .TotalSize }}
+:last vacuum: {{ .LastVacuumAt }}
+:last analyze: {{ .LastAnalyzeAt }}
+{{ end }}
+{{- if .IsPartitioned }}
+Partitioned by {{ .PartitionKey.String }} into {{ len .Partitions }} partitions.
+{{ if .Partitions }}
+.. csv-table:: {{ .Name }} partitions
+   :header: partition,bound
+{{ range .Partitions }}
+   "{{ .Name | csv }}", "{{ .Bound | csv }}"
+{{- end }}
+{{ end }}
+{{- end }}
+{{ if .HasColumnStats -}}
+.. tabularcolumns:: |p{2cm}|p{2cm}|p{2cm}|p{2cm}|p{3cm}|p{1cm}|p{1cm}|p{2cm}|
+{{- else -}}
+.. tabularcolumns:: |p{3cm}|p{3cm}|p{3cm}|p{3cm}|p{4cm}|
+{{- end }}
 
 .. csv-table:: {{ .Name }}
-   :header: column,type,description
+   :header: column,type,references,allowed values,description {{- if .HasColumnStats }},null,distinct,common values{{- end }}
 {{ range .Columns }}
-   "{{ .Name }}", "{{ .DataType }}", "{{- if .Comment.Valid }}{{ .Comment.String }} {{- else }}TODO_ADD_COMMENT{{- end }}"
+   "{{ .Name | csv }}", "{{ .DataType | csv }}", "{{ $.References .Name | csv }}", "{{ .EnumValueList | csv }}", "{{- if .Comment.Valid }}{{ .Comment.String | csv }} {{- else }}TODO_ADD_COMMENT{{- end }}"
+   {{- if $.HasColumnStats }}, {{ with .Stats }}"{{ .NullPercent }}", "{{ .Distinct }}", "{{ .MostCommonValueList }}"{{ else }}"", "", ""{{ end }}{{- end }}
+{{- end }}
+{{ if .UniqueGroups }}
+.. csv-table:: {{ .Name }} unique groups
+   :header: group,constraint,columns
+{{ range .UniqueGroups }}
+   "{{ $.UniqueGroupMarker . }}", "{{ .Name | csv }}", "{{ .ColumnList | csv }}"
+{{- end }}
+{{ end }}
+{{- if .Indexes }}
+.. csv-table:: {{ .Name }} indexes
+   :header: index,method,columns,unique,predicate
+{{ range .Indexes }}
+   "{{ .Name | csv }}", "{{ .Method }}", "{{ .ColumnList | csv }}", "{{ if .IsUnique }}yes{{ end }}", "{{ .Predicate.String | csv }}"
+{{- end }}
+{{ end }}
+{{- if .Constraints }}
+.. csv-table:: {{ .Name }} constraints
+   :header: constraint,type,columns,definition
+{{ range .Constraints }}
+   "{{ .Name | csv }}", "{{ .TypeName }}", "{{ .ColumnList | csv }}", "{{ .Definition | csv }}"
+{{- end }}
+{{ end }}
+{{- if .Triggers }}
+.. csv-table:: {{ .Name }} triggers
+   :header: trigger,timing,events,level,function,enabled
+{{ range .Triggers }}
+   "{{ .Name | csv }}", "{{ .Timing }}", "{{ .EventList }}", "{{ .Level }}", "{{ .Function | csv }}", "{{ if .Enabled }}yes{{ else }}no{{ end }}"
+{{- end }}
+{{ end }}
+{{- if or .RowSecurity .Policies .Grants }}
+**access**
+{{ if .RowSecurity }}
+Row level security is enabled {{- if .ForceRowSecurity }} and forced for the table owner{{- end }}.
+{{ end }}
+{{- if .Policies }}
+.. csv-table:: {{ .Name }} policies
+   :header: policy,command,type,roles,using,with check
+{{ range .Policies }}
+   "{{ .Name | csv }}", "{{ .Command }}", "{{ .TypeName }}", "{{ .RoleList | csv }}", "{{ .Using.String | csv }}", "{{ .WithCheck.String | csv }}"
+{{- end }}
+{{ end }}
+{{- if .Grants }}
+.. csv-table:: {{ .Name }} privileges
+   :header: grantee,column,privileges
+{{ range .Grants }}
+   "{{ .Grantee | csv }}", "{{ .Column | csv }}", "{{ .Privileges | csv }}"
+{{- end }}
+{{ end }}
 {{- end }}
 `
+
+const diffTextTmpl = `{{ if .IsEmpty }}no differences
+{{ end -}}
+{{ range .Tables -}}
+{{ .Mark }} table {{ .FullName }}
+{{ if eq .Kind "changed" }}{{ range .Changes }}    {{ .Field }}: {{ printf "%q" .Old }} -> {{ printf "%q" .New }}
+{{ end }}{{ end -}}
+{{ range .Columns }}    {{ .Mark }} column {{ .Name }}{{ if eq .Kind "changed" }}
+{{ range .Changes }}        {{ .Field }}: {{ printf "%q" .Old }} -> {{ printf "%q" .New }}
+{{ end }}{{ else }} {{ .Definition }}
+{{ end }}{{ end -}}
+{{ range .ForeignKeys }}    {{ .Mark }} foreign key {{ .Name }}{{ if eq .Kind "changed" }}
+{{ range .Changes }}        {{ .Field }}: {{ .Old }} -> {{ .New }}
+{{ end }}{{ else }} {{ .Definition }}
+{{ end }}{{ end -}}
+{{ end -}}
+{{ range .Types -}}
+{{ .Mark }} {{ .Type }} {{ .Schema }}.{{ .Name }}
+{{ range .Changes }}    {{ .Field }}: {{ printf "%q" .Old }} -> {{ printf "%q" .New }}
+{{ end -}}
+{{ end }}`